@@ -0,0 +1,34 @@
+package main
+
+import "fmt"
+
+// normalizeBarcode validates an EAN-8, UPC-A or EAN-13 code (check digit
+// included) and returns its canonical form. UPC-A codes are stored as their
+// EAN-13 equivalent ("0" + UPC) so both scans find the same product.
+func normalizeBarcode(code string) (string, error) {
+	for _, c := range code {
+		if c < '0' || c > '9' { return "", fmt.Errorf("barcode %q: digits only", code) }
+	}
+	switch len(code) {
+	case 8, 13:
+	case 12:
+		code = "0" + code
+	default:
+		return "", fmt.Errorf("barcode %q: want 8, 12 or 13 digits", code)
+	}
+	if !validCheckDigit(code) { return "", fmt.Errorf("barcode %q: bad check digit", code) }
+	return code, nil
+}
+
+// validCheckDigit implements the GS1 mod-10 check shared by EAN/UPC: from
+// the right, digits alternate weights 3 and 1 starting after the check digit.
+func validCheckDigit(code string) bool {
+	sum := 0
+	body := code[:len(code)-1]
+	for i := range body {
+		d := int(body[len(body)-1-i] - '0')
+		if i%2 == 0 { d *= 3 }
+		sum += d
+	}
+	return (10-sum%10)%10 == int(code[len(code)-1]-'0')
+}
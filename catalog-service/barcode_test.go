@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestNormalizeBarcode(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		want    string
+		wantErr bool
+	}{
+		{"EAN-13", "4006381333931", "4006381333931", false},
+		{"EAN-13, GS1 example", "5901234123457", "5901234123457", false},
+		{"EAN-8", "96385074", "96385074", false},
+		{"UPC-A stored as EAN-13", "036000291452", "0036000291452", false},
+		{"UPC-A and its EAN-13 form agree", "0036000291452", "0036000291452", false},
+		{"bad EAN-13 check digit", "4006381333932", "", true},
+		{"bad EAN-8 check digit", "96385075", "", true},
+		{"bad UPC-A check digit", "036000291453", "", true},
+		{"letters", "40063813339X1", "", true},
+		{"spaces", "4006381 333931", "", true},
+		{"too short", "1234567", "", true},
+		{"GTIN-14 not accepted", "14006381333938", "", true},
+		{"empty", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeBarcode(tt.code)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("normalizeBarcode(%q) = %q, %v; want %q, error %v", tt.code, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidCheckDigit(t *testing.T) {
+	// every check digit of one body: exactly one is right
+	for d := byte('0'); d <= '9'; d++ {
+		code := "590123412345" + string(d)
+		if got, want := validCheckDigit(code), d == '7'; got != want {
+			t.Errorf("validCheckDigit(%q) = %v, want %v", code, got, want)
+		}
+	}
+	for _, code := range []string{"00000000", "0000000000000", "12345670", "4012345678901", "0012345678905"} {
+		if !validCheckDigit(code) { t.Errorf("validCheckDigit(%q) = false, want true", code) }
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── brands ───────────────────────────────────────────────────────────── */
+
+// Brand mirrors brands/{id}.
+type Brand struct {
+	ID        string    `firestore:"id"        json:"id"`
+	Name      string    `firestore:"name"      json:"name"`
+	NameLower string    `firestore:"nameLower" json:"-"` // case-insensitive ordering
+	LogoURL   string    `firestore:"logoURL"   json:"logoURL,omitempty"`
+	Website   string    `firestore:"website"   json:"website,omitempty"`
+	CreatedAt time.Time `firestore:"createdAt" json:"-"`
+	UpdatedAt time.Time `firestore:"updatedAt" json:"-"`
+}
+
+type brandResponse struct {
+	Brand
+	CreatedAt string `json:"createdAt"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+func newBrandResponse(b Brand) brandResponse {
+	return brandResponse{Brand: b, CreatedAt: models.FormatTime(b.CreatedAt), UpdatedAt: models.FormatTime(b.UpdatedAt)}
+}
+
+type brandRequest struct {
+	Name    string `json:"name"`
+	LogoURL string `json:"logoURL"`
+	Website string `json:"website"`
+}
+
+func listBrands(w http.ResponseWriter, r *http.Request) {
+	col := fs.Collection("brands")
+	limit := paging.Limit(r, 50, 200)
+	q, err := paging.StartAfter(r, col, col.OrderBy("nameLower", firestore.Asc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	page := paging.Page[brandResponse]{Items: make([]brandResponse, 0, len(docs))}
+	for _, d := range docs {
+		var b Brand
+		if err := d.DataTo(&b); err != nil { continue }
+		page.Items = append(page.Items, newBrandResponse(b))
+	}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	writeJSON(w, http.StatusOK, page)
+}
+
+func getBrand(w http.ResponseWriter, r *http.Request) {
+	doc, err := fs.Collection("brands").Doc(chi.URLParam(r, "id")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var b Brand
+	if err := doc.DataTo(&b); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newBrandResponse(b))
+}
+
+func createBrand(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	req, ok := decodeBrand(w, r)
+	if !ok { return }
+
+	ref := fs.Collection("brands").NewDoc()
+	now := time.Now().UTC()
+	b := Brand{ID: ref.ID, Name: req.Name, NameLower: strings.ToLower(req.Name), LogoURL: req.LogoURL, Website: req.Website, CreatedAt: now, UpdatedAt: now}
+	if _, err := ref.Create(r.Context(), b); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newBrandResponse(b))
+}
+
+// updateBrand replaces the brand; a rename is copied onto its products,
+// which carry brandName for cheap summaries.
+func updateBrand(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	req, ok := decodeBrand(w, r)
+	if !ok { return }
+
+	ref := fs.Collection("brands").Doc(chi.URLParam(r, "id"))
+	doc, err := ref.Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var b Brand
+	if err := doc.DataTo(&b); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	renamed := b.Name != req.Name
+	b.Name, b.NameLower, b.LogoURL, b.Website = req.Name, strings.ToLower(req.Name), req.LogoURL, req.Website
+	b.UpdatedAt = time.Now().UTC()
+	if _, err := ref.Set(r.Context(), b); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+
+	if renamed {
+		docs, err := fs.Collection("products").Where("brandID", "==", b.ID).Documents(r.Context()).GetAll()
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		for _, chunk := range chunkDocs(docs, 400) {
+			batch := fs.Batch()
+			for _, d := range chunk {
+				batch.Update(d.Ref, []firestore.Update{{Path: "brandName", Value: b.Name}})
+			}
+			if _, err := batch.Commit(r.Context()); err != nil {
+				http.Error(w, "db write err", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, newBrandResponse(b))
+}
+
+// deleteBrand refuses while products still reference the brand.
+func deleteBrand(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	id := chi.URLParam(r, "id")
+
+	docs, err := fs.Collection("products").Where("brandID", "==", id).Limit(1).Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	if len(docs) > 0 {
+		http.Error(w, "brand still has products", http.StatusConflict)
+		return
+	}
+	if _, err := fs.Collection("brands").Doc(id).Delete(r.Context()); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func decodeBrand(w http.ResponseWriter, r *http.Request) (brandRequest, bool) {
+	var req brandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return req, false
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+func chunkDocs(docs []*firestore.DocumentSnapshot, n int) [][]*firestore.DocumentSnapshot {
+	var out [][]*firestore.DocumentSnapshot
+	for len(docs) > 0 {
+		if len(docs) < n { n = len(docs) }
+		out = append(out, docs[:n])
+		docs = docs[n:]
+	}
+	return out
+}
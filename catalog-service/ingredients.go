@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/inci"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+/* ────── ingredient analysis ──────────────────────────────────────────────── */
+
+type analyzeRequest struct {
+	Raw string `json:"raw"` // INCI list as printed on the pack
+}
+
+type analyzeResponse struct {
+	inci.Analysis
+	AvoidMatches []string `json:"avoidMatches,omitempty"`
+}
+
+// analyzeIngredients parses a pasted INCI list that is not (yet) in the
+// catalog, e.g. from a photo of the pack.
+func analyzeIngredients(w http.ResponseWriter, r *http.Request) {
+	var req analyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if len(req.Raw) > 10_000 {
+		http.Error(w, "ingredient list too long", http.StatusBadRequest)
+		return
+	}
+	ingredients := inci.Parse(req.Raw)
+	writeJSON(w, http.StatusOK, analyzeResponse{
+		Analysis:     inci.Analyze(ingredients),
+		AvoidMatches: inci.Matches(ingredients, models.LoadAvoidList(r.Context(), fs, viewer(r))),
+	})
+}
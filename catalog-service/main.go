@@ -0,0 +1,125 @@
+// cmd/catalog-service/main.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+/* ────── env vars ─────────────────────────────────────────────────────────── */
+
+var (
+	projectID string // GOOGLE_CLOUD_PROJECT, read in main so that tests can load the package
+	port      = "8080"
+)
+
+/* ────── globals (initialised in main) ────────────────────────────────────── */
+
+var (
+	ctx context.Context
+	fs  *firestore.Client
+)
+
+/* ────── main ─────────────────────────────────────────────────────────────── */
+
+func main() {
+	ctx = context.Background()
+	projectID = mustEnv("GOOGLE_CLOUD_PROJECT")
+
+	var err error
+	if fs, err = firestore.NewClient(ctx, projectID); err != nil {
+		log.Fatalf("firestore: %v", err)
+	}
+	if err = auth.Init(ctx); err != nil {
+		log.Fatalf("auth init: %v", err)
+	}
+	// media URL signing for posts linked from reviews
+	store, err := blobstore.FromEnv(ctx)
+	if err != nil { log.Fatalf("storage: %v", err) }
+	if !store.CanSign() { log.Fatal("storage: no signing credentials") }
+	signing.Init(store)
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger, middleware.RequestID, middleware.Timeout(15*time.Second))
+
+	// public browsing
+	r.Get("/brands", listBrands)
+	r.Get("/brands/{id}", getBrand)
+	r.Get("/products", listProducts)
+	r.Get("/products/{id}", getProduct)
+	r.Get("/barcodes/{code}", productByBarcode)
+	r.Post("/ingredients/analyze", analyzeIngredients)
+	r.Get("/products/{id}/rating", productRating)
+	r.Get("/products/{id}/reviews", listReviews)
+	r.Get("/products/{id}/reviews/{rid}", getReview)
+
+	// reviews (signed in)
+	r.Post("/products/{id}/reviews", createReview)
+	r.Put("/products/{id}/reviews/{rid}", editReview)
+	r.Delete("/products/{id}/reviews/{rid}", deleteReview)
+	r.Post("/products/{id}/reviews/{rid}/helpful", markHelpful)
+	r.Delete("/products/{id}/reviews/{rid}/helpful", unmarkHelpful)
+
+	// admin CRUD
+	r.Post("/brands", createBrand)
+	r.Put("/brands/{id}", updateBrand)
+	r.Delete("/brands/{id}", deleteBrand)
+	r.Post("/products", createProduct)
+	r.Put("/products/{id}", updateProduct)
+	r.Delete("/products/{id}", deleteProduct)
+
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("catalog-svc OK")) })
+
+	log.Printf("catalog-service listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, r))
+}
+
+/* ────── helpers ─────────────────────────────────────────────────────────── */
+
+// requireAdmin answers 401/403 unless the caller carries the admin claim.
+func requireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	uid, err := auth.VerifyClaim(r.Context(), r, auth.ClaimAdmin)
+	if errors.Is(err, auth.ErrMissingClaim) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return "", false
+	}
+	return uid, true
+}
+
+// viewer is the signed-in caller, or "" for anonymous browsing.
+func viewer(r *http.Request) string {
+	if r.Header.Get("Authorization") == "" { return "" }
+	uid, _ := auth.VerifyFirebaseToken(r.Context(), r)
+	return uid
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func mustEnv(k string) string {
+	v := os.Getenv(k)
+	if v == "" {
+		log.Fatalf("missing env %s", k)
+	}
+	return v
+}
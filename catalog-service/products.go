@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/inci"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── products ─────────────────────────────────────────────────────────── */
+
+// Categories a product may belong to.
+var categories = map[string]bool{"skincare": true, "makeup": true, "haircare": true}
+
+var hexColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+const (
+	maxShades      = 100
+	maxSizes       = 20
+	maxIngredients = 200
+	maxBarcodes    = 20
+)
+
+type Shade struct {
+	Name string `firestore:"name" json:"name"`
+	Hex  string `firestore:"hex"  json:"hex"`
+}
+
+type Size struct {
+	Label  string  `firestore:"label"  json:"label"`
+	Amount float64 `firestore:"amount" json:"amount"`
+	Unit   string  `firestore:"unit"   json:"unit"` // ml, g, oz …
+}
+
+// Product mirrors products/{id}. name, brandName, category and imageURL are
+// also what models.ProductSummary reads for post product tags.
+type Product struct {
+	ID             string    `firestore:"id"             json:"id"`
+	Name           string    `firestore:"name"           json:"name"`
+	NameLower      string    `firestore:"nameLower"      json:"-"`
+	BrandID        string    `firestore:"brandID"        json:"brandID"`
+	BrandName      string    `firestore:"brandName"      json:"brandName"`
+	Category       string    `firestore:"category"       json:"category"`
+	Description    string    `firestore:"description"    json:"description,omitempty"`
+	ImageURL       string    `firestore:"imageURL"       json:"imageURL,omitempty"`
+	Shades         []Shade   `firestore:"shades"         json:"shades"`
+	Sizes          []Size    `firestore:"sizes"          json:"sizes"`
+	Ingredients    []string  `firestore:"ingredients"    json:"ingredients"` // canonical INCI names
+	IngredientsRaw string    `firestore:"ingredientsRaw" json:"ingredientsRaw,omitempty"`
+	Barcodes       []string  `firestore:"barcodes"       json:"barcodes"`
+	CreatedAt      time.Time `firestore:"createdAt"      json:"-"`
+	UpdatedAt      time.Time `firestore:"updatedAt"      json:"-"`
+
+	Rating RatingStats `firestore:"rating" json:"-"` // maintained by reviews.go
+}
+
+type productResponse struct {
+	Product
+	CreatedAt string        `json:"createdAt"`
+	UpdatedAt string        `json:"updatedAt"`
+	Rating    ratingSummary `json:"rating"`
+
+	Analysis     *inci.Analysis `json:"analysis,omitempty"`
+	AvoidMatches []string       `json:"avoidMatches,omitempty"`
+}
+
+func newProductResponse(p Product) productResponse {
+	return productResponse{Product: p, CreatedAt: models.FormatTime(p.CreatedAt), UpdatedAt: models.FormatTime(p.UpdatedAt),
+		Rating: newRatingSummary(p.Rating)}
+}
+
+// detailResponse is a product page: the ingredient analysis plus, for a
+// signed-in caller, the ingredients on their avoid list.
+func detailResponse(r *http.Request, p Product) productResponse {
+	resp := newProductResponse(p)
+	a := inci.Analyze(p.Ingredients)
+	resp.Analysis = &a
+	resp.AvoidMatches = inci.Matches(p.Ingredients, models.LoadAvoidList(r.Context(), fs, viewer(r)))
+	return resp
+}
+
+type productRequest struct {
+	Name        string   `json:"name"`
+	BrandID     string   `json:"brandID"`
+	Category    string   `json:"category"`
+	Description string   `json:"description"`
+	ImageURL    string   `json:"imageURL"`
+	Shades      []Shade  `json:"shades"`
+	Sizes       []Size   `json:"sizes"`
+	Ingredients []string `json:"ingredients"`
+	Barcodes    []string `json:"barcodes"`
+
+	// IngredientsRaw is the INCI list as printed on the pack; when set it
+	// is parsed and replaces Ingredients.
+	IngredientsRaw string `json:"ingredientsRaw"`
+}
+
+// listProducts pages through products by name, optionally narrowed to one
+// brand and/or category.
+func listProducts(w http.ResponseWriter, r *http.Request) {
+	col := fs.Collection("products")
+	q := col.Query
+	if b := r.URL.Query().Get("brandID"); b != "" { q = q.Where("brandID", "==", b) }
+	if c := r.URL.Query().Get("category"); c != "" {
+		if !categories[c] {
+			http.Error(w, "unknown category", http.StatusBadRequest)
+			return
+		}
+		q = q.Where("category", "==", c)
+	}
+	limit := paging.Limit(r, 20, 100)
+	q, err := paging.StartAfter(r, col, q.OrderBy("nameLower", firestore.Asc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	page := paging.Page[productResponse]{Items: make([]productResponse, 0, len(docs))}
+	for _, d := range docs {
+		var p Product
+		if err := d.DataTo(&p); err != nil { continue }
+		page.Items = append(page.Items, newProductResponse(p))
+	}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	writeJSON(w, http.StatusOK, page)
+}
+
+func getProduct(w http.ResponseWriter, r *http.Request) {
+	doc, err := fs.Collection("products").Doc(chi.URLParam(r, "id")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var p Product
+	if err := doc.DataTo(&p); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, detailResponse(r, p))
+}
+
+// productByBarcode resolves a scanned EAN/UPC code to its product.
+func productByBarcode(w http.ResponseWriter, r *http.Request) {
+	code, err := normalizeBarcode(chi.URLParam(r, "code"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	docs, err := fs.Collection("products").Where("barcodes", "array-contains", code).Limit(1).Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	if len(docs) == 0 {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var p Product
+	if err := docs[0].DataTo(&p); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, detailResponse(r, p))
+}
+
+func createProduct(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	ref := fs.Collection("products").NewDoc()
+	p, ok := productFromRequest(w, r, ref.ID)
+	if !ok { return }
+
+	p.CreatedAt = p.UpdatedAt
+	if _, err := ref.Create(r.Context(), p); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusCreated, newProductResponse(p))
+}
+
+func updateProduct(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	ref := fs.Collection("products").Doc(chi.URLParam(r, "id"))
+	if _, err := ref.Get(r.Context()); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	p, ok := productFromRequest(w, r, ref.ID)
+	if !ok { return }
+
+	// createdAt and the review stats are not part of the request; re-read
+	// them in the transaction so concurrent reviews are not overwritten.
+	err := fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		var old Product
+		if err := doc.DataTo(&old); err != nil { return err }
+		p.CreatedAt, p.Rating = old.CreatedAt, old.Rating
+		return tx.Set(ref, p)
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	writeJSON(w, http.StatusOK, newProductResponse(p))
+}
+
+// deleteProduct removes the product with its reviews and their votes.
+func deleteProduct(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok { return }
+	ref := fs.Collection("products").Doc(chi.URLParam(r, "id"))
+	if err := fsutil.DeleteSubcollections(r.Context(), fs, ref); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	if _, err := ref.Delete(r.Context()); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// productFromRequest decodes and validates the body, resolves the brand and
+// checks that none of the barcodes already belong to another product.
+func productFromRequest(w http.ResponseWriter, r *http.Request, id string) (Product, bool) {
+	var req productRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return Product{}, false
+	}
+	if msg := validateProduct(&req); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return Product{}, false
+	}
+
+	brand, err := fs.Collection("brands").Doc(req.BrandID).Get(r.Context())
+	if err != nil {
+		http.Error(w, "unknown brand", http.StatusBadRequest)
+		return Product{}, false
+	}
+	brandName, _ := brand.DataAt("name")
+
+	for _, code := range req.Barcodes {
+		docs, err := fs.Collection("products").Where("barcodes", "array-contains", code).Limit(1).Documents(r.Context()).GetAll()
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return Product{}, false
+		}
+		if len(docs) > 0 && docs[0].Ref.ID != id {
+			http.Error(w, "barcode "+code+" already assigned", http.StatusConflict)
+			return Product{}, false
+		}
+	}
+
+	name, _ := brandName.(string)
+	return Product{
+		ID:             id,
+		Name:           req.Name,
+		NameLower:      strings.ToLower(req.Name),
+		BrandID:        req.BrandID,
+		BrandName:      name,
+		Category:       req.Category,
+		Description:    req.Description,
+		ImageURL:       req.ImageURL,
+		Shades:         req.Shades,
+		Sizes:          req.Sizes,
+		Ingredients:    req.Ingredients,
+		IngredientsRaw: req.IngredientsRaw,
+		Barcodes:       req.Barcodes,
+		UpdatedAt:      time.Now().UTC(),
+	}, true
+}
+
+// validateProduct checks the request and normalises it in place: trimmed
+// strings, canonical barcodes and non-nil slices.
+func validateProduct(req *productRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" { return "name required" }
+	if req.BrandID == "" { return "brandID required" }
+	if !categories[req.Category] { return "category must be skincare, makeup or haircare" }
+
+	if len(req.Shades) > maxShades { return fmt.Sprintf("at most %d shades", maxShades) }
+	for i, s := range req.Shades {
+		if strings.TrimSpace(s.Name) == "" { return fmt.Sprintf("shade %d: name required", i) }
+		if !hexColor.MatchString(s.Hex) { return fmt.Sprintf("shade %d: hex must look like #A1B2C3", i) }
+		req.Shades[i].Hex = strings.ToUpper(s.Hex)
+	}
+
+	if len(req.Sizes) > maxSizes { return fmt.Sprintf("at most %d sizes", maxSizes) }
+	for i, s := range req.Sizes {
+		if s.Amount <= 0 || strings.TrimSpace(s.Unit) == "" { return fmt.Sprintf("size %d: amount and unit required", i) }
+	}
+
+	req.IngredientsRaw = strings.TrimSpace(req.IngredientsRaw)
+	if req.IngredientsRaw != "" {
+		req.Ingredients = inci.Parse(req.IngredientsRaw)
+	}
+	if len(req.Ingredients) > maxIngredients { return fmt.Sprintf("at most %d ingredients", maxIngredients) }
+	ingredients := make([]string, 0, len(req.Ingredients))
+	for _, in := range req.Ingredients {
+		if in = inci.Normalize(in); in != "" { ingredients = append(ingredients, in) }
+	}
+	req.Ingredients = ingredients
+
+	if len(req.Barcodes) > maxBarcodes { return fmt.Sprintf("at most %d barcodes", maxBarcodes) }
+	seen := map[string]bool{}
+	barcodes := make([]string, 0, len(req.Barcodes))
+	for _, b := range req.Barcodes {
+		code, err := normalizeBarcode(strings.TrimSpace(b))
+		if err != nil { return err.Error() }
+		if !seen[code] {
+			seen[code] = true
+			barcodes = append(barcodes, code)
+		}
+	}
+	req.Barcodes = barcodes
+
+	if req.Shades == nil { req.Shades = []Shade{} }
+	if req.Sizes == nil { req.Sizes = []Size{} }
+	return ""
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── reviews ──────────────────────────────────────────────────────────── */
+
+// Reviews live at products/{productID}/reviews/{authorUID}; keying by author
+// enforces one review per user and product. Helpful votes are
+// reviews/{authorUID}/helpful/{voterUID}.
+
+var skinTypes = map[string]bool{"dry": true, "oily": true, "combination": true, "normal": true, "sensitive": true}
+
+const (
+	maxReviewTitle = 150
+	maxReviewText  = 5000
+	maxProsCons    = 10
+	maxProConLen   = 200
+)
+
+type Review struct {
+	ID           string    `firestore:"-"            json:"id"` // == AuthorID
+	ProductID    string    `firestore:"productID"    json:"productID"`
+	AuthorID     string    `firestore:"authorID"     json:"-"`
+	Rating       int       `firestore:"rating"       json:"rating"`
+	SkinType     string    `firestore:"skinType"     json:"skinType"`
+	Title        string    `firestore:"title"        json:"title,omitempty"`
+	Text         string    `firestore:"text"         json:"text"`
+	Pros         []string  `firestore:"pros"         json:"pros"`
+	Cons         []string  `firestore:"cons"         json:"cons"`
+	PostID       string    `firestore:"postID"       json:"postID,omitempty"`
+	HelpfulCount int64     `firestore:"helpfulCount" json:"helpfulCount"`
+	Edited       bool      `firestore:"edited"       json:"edited"`
+	CreatedAt    time.Time `firestore:"createdAt"    json:"-"`
+	UpdatedAt    time.Time `firestore:"updatedAt"    json:"-"`
+}
+
+type reviewResponse struct {
+	Review
+	Author      models.AuthorSummary `json:"author"`
+	Post        *models.PostResponse `json:"post,omitempty"` // linked post with photos
+	HelpfulByMe bool                 `json:"helpfulByMe"`
+	CreatedAt   string               `json:"createdAt"`
+	UpdatedAt   string               `json:"updatedAt"`
+}
+
+type reviewRequest struct {
+	Rating   int      `json:"rating"`
+	SkinType string   `json:"skinType"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Pros     []string `json:"pros"`
+	Cons     []string `json:"cons"`
+	PostID   string   `json:"postID"`
+}
+
+func reviewsCol(productID string) *firestore.CollectionRef {
+	return fs.Collection("products").Doc(productID).Collection("reviews")
+}
+
+// createReview adds the caller's review; a second one answers 409 – edit
+// the existing review instead.
+func createReview(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	req, ok := decodeReview(w, r, uid)
+	if !ok { return }
+
+	productRef := fs.Collection("products").Doc(chi.URLParam(r, "id"))
+	ref := reviewsCol(productRef.ID).Doc(uid)
+	now := time.Now().UTC()
+	rev := Review{
+		ID: uid, ProductID: productRef.ID, AuthorID: uid,
+		Rating: req.Rating, SkinType: req.SkinType, Title: req.Title, Text: req.Text,
+		Pros: req.Pros, Cons: req.Cons, PostID: req.PostID,
+		CreatedAt: now, UpdatedAt: now,
+	}
+
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		if _, err := tx.Get(productRef); err != nil { return err }
+		if doc, err := tx.Get(ref); err == nil && doc.Exists() {
+			return status.Error(codes.AlreadyExists, "already reviewed")
+		} else if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err := tx.Create(ref, rev); err != nil { return err }
+		return tx.Update(productRef, ratingUpdates(nil, &rev))
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		http.Error(w, "already reviewed", http.StatusConflict)
+		return
+	}
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	writeJSON(w, http.StatusCreated, reviewResponses(r.Context(), uid, []Review{rev})[0])
+}
+
+// editReview replaces the caller's review, moving its rating between
+// buckets of the product stats.
+func editReview(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	if chi.URLParam(r, "rid") != uid {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	req, ok := decodeReview(w, r, uid)
+	if !ok { return }
+
+	productRef := fs.Collection("products").Doc(chi.URLParam(r, "id"))
+	ref := reviewsCol(productRef.ID).Doc(uid)
+	var rev Review
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if err := doc.DataTo(&rev); err != nil { return err }
+		old := rev
+
+		rev.ID = uid
+		rev.Rating, rev.SkinType, rev.Title, rev.Text = req.Rating, req.SkinType, req.Title, req.Text
+		rev.Pros, rev.Cons, rev.PostID = req.Pros, req.Cons, req.PostID
+		rev.Edited, rev.UpdatedAt = true, time.Now().UTC()
+		if err := tx.Set(ref, rev); err != nil { return err }
+		return tx.Update(productRef, ratingUpdates(&old, &rev))
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	writeJSON(w, http.StatusOK, reviewResponses(r.Context(), uid, []Review{rev})[0])
+}
+
+// deleteReview removes a review; its author and catalog admins may do so.
+func deleteReview(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	rid := chi.URLParam(r, "rid")
+	if rid != uid {
+		if _, err := auth.VerifyClaim(r.Context(), r, auth.ClaimAdmin); err != nil {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	productRef := fs.Collection("products").Doc(chi.URLParam(r, "id"))
+	ref := reviewsCol(productRef.ID).Doc(rid)
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		var old Review
+		if err := doc.DataTo(&old); err != nil { return err }
+		if err := tx.Delete(ref); err != nil { return err }
+		return tx.Update(productRef, ratingUpdates(&old, nil))
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	if err := fsutil.DeleteCollection(r.Context(), fs, ref.Collection("helpful")); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listReviews pages through a product's reviews. sort is newest (default),
+// helpful, highest or lowest; skinType narrows to one skin type.
+func listReviews(w http.ResponseWriter, r *http.Request) {
+	col := reviewsCol(chi.URLParam(r, "id"))
+	q := col.Query
+	if st := r.URL.Query().Get("skinType"); st != "" {
+		if !skinTypes[st] {
+			http.Error(w, "unknown skinType", http.StatusBadRequest)
+			return
+		}
+		q = q.Where("skinType", "==", st)
+	}
+	switch r.URL.Query().Get("sort") {
+	case "", "newest":
+		q = q.OrderBy("createdAt", firestore.Desc)
+	case "helpful":
+		q = q.OrderBy("helpfulCount", firestore.Desc).OrderBy("createdAt", firestore.Desc)
+	case "highest":
+		q = q.OrderBy("rating", firestore.Desc).OrderBy("createdAt", firestore.Desc)
+	case "lowest":
+		q = q.OrderBy("rating", firestore.Asc).OrderBy("createdAt", firestore.Desc)
+	default:
+		http.Error(w, "sort must be newest, helpful, highest or lowest", http.StatusBadRequest)
+		return
+	}
+	limit := paging.Limit(r, 20, 50)
+	q, err := paging.StartAfter(r, col, q.Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	reviews := make([]Review, 0, len(docs))
+	for _, d := range docs {
+		var rev Review
+		if err := d.DataTo(&rev); err != nil { continue }
+		rev.ID = d.Ref.ID
+		reviews = append(reviews, rev)
+	}
+	page := paging.Page[reviewResponse]{Items: reviewResponses(r.Context(), viewer(r), reviews)}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	writeJSON(w, http.StatusOK, page)
+}
+
+func getReview(w http.ResponseWriter, r *http.Request) {
+	doc, err := reviewsCol(chi.URLParam(r, "id")).Doc(chi.URLParam(r, "rid")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var rev Review
+	if err := doc.DataTo(&rev); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	rev.ID = doc.Ref.ID
+	writeJSON(w, http.StatusOK, reviewResponses(r.Context(), viewer(r), []Review{rev})[0])
+}
+
+/* ────── helpfulness votes ────────────────────────────────────────────────── */
+
+type helpfulResponse struct {
+	Helpful      bool  `json:"helpful"`
+	HelpfulCount int64 `json:"helpfulCount"`
+}
+
+func markHelpful(w http.ResponseWriter, r *http.Request)   { setHelpful(w, r, true) }
+func unmarkHelpful(w http.ResponseWriter, r *http.Request) { setHelpful(w, r, false) }
+
+// setHelpful idempotently records or withdraws the caller's helpful vote;
+// authors cannot vote on their own review.
+func setHelpful(w http.ResponseWriter, r *http.Request, helpful bool) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	ref := reviewsCol(chi.URLParam(r, "id")).Doc(chi.URLParam(r, "rid"))
+	if ref.ID == uid {
+		http.Error(w, "cannot vote on own review", http.StatusBadRequest)
+		return
+	}
+	voteRef := ref.Collection("helpful").Doc(uid)
+
+	var count int64
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		count, _ = doc.Data()["helpfulCount"].(int64)
+
+		existing, err := tx.Get(voteRef)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+		if exists := err == nil && existing.Exists(); exists == helpful { return nil }
+
+		delta := int64(1)
+		if helpful {
+			err = tx.Create(voteRef, map[string]any{"uid": uid, "timestamp": firestore.ServerTimestamp})
+		} else {
+			delta = -1
+			err = tx.Delete(voteRef)
+		}
+		if err != nil { return err }
+		count += delta
+		return tx.Update(ref, []firestore.Update{{Path: "helpfulCount", Value: firestore.Increment(delta)}})
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	writeJSON(w, http.StatusOK, helpfulResponse{Helpful: helpful, HelpfulCount: count})
+}
+
+/* ────── rating stats ─────────────────────────────────────────────────────── */
+
+// RatingStats is the running aggregate kept on the product doc under
+// "rating" by the review handlers.
+type RatingStats struct {
+	Count     int64                    `firestore:"count"`
+	Sum       int64                    `firestore:"sum"`
+	Dist      map[string]int64         `firestore:"dist"` // "1".."5" → reviews
+	SkinTypes map[string]SkinTypeStats `firestore:"skinTypes"`
+	UpdatedAt time.Time                `firestore:"updatedAt"`
+}
+
+type SkinTypeStats struct {
+	Count int64 `firestore:"count"`
+	Sum   int64 `firestore:"sum"`
+}
+
+type ratingSummary struct {
+	Average      float64                    `json:"average"`
+	Count        int64                      `json:"count"`
+	Distribution map[string]int64           `json:"distribution"`
+	BySkinType   map[string]skinTypeSummary `json:"bySkinType"`
+}
+
+type skinTypeSummary struct {
+	Average float64 `json:"average"`
+	Count   int64   `json:"count"`
+}
+
+func newRatingSummary(s RatingStats) ratingSummary {
+	out := ratingSummary{Count: s.Count, Average: average(s.Sum, s.Count),
+		Distribution: map[string]int64{}, BySkinType: map[string]skinTypeSummary{}}
+	for star := 1; star <= 5; star++ {
+		out.Distribution[strconv.Itoa(star)] = s.Dist[strconv.Itoa(star)]
+	}
+	for st, v := range s.SkinTypes {
+		if v.Count > 0 { out.BySkinType[st] = skinTypeSummary{Average: average(v.Sum, v.Count), Count: v.Count} }
+	}
+	return out
+}
+
+// average rounds to one decimal, as shown next to the stars.
+func average(sum, count int64) float64 {
+	if count == 0 { return 0 }
+	return math.Round(float64(sum)/float64(count)*10) / 10
+}
+
+// ratingUpdates moves a review's contribution out of (old) and into (new)
+// the product's stats; either may be nil.
+func ratingUpdates(old, new *Review) []firestore.Update {
+	delta := map[string]int64{}
+	apply := func(rev *Review, sign int64) {
+		if rev == nil { return }
+		delta["rating.count"] += sign
+		delta["rating.sum"] += sign * int64(rev.Rating)
+		delta[fmt.Sprintf("rating.dist.%d", rev.Rating)] += sign
+		delta["rating.skinTypes."+rev.SkinType+".count"] += sign
+		delta["rating.skinTypes."+rev.SkinType+".sum"] += sign * int64(rev.Rating)
+	}
+	apply(old, -1)
+	apply(new, 1)
+
+	var ups []firestore.Update
+	for path, d := range delta {
+		if d != 0 { ups = append(ups, firestore.Update{Path: path, Value: firestore.Increment(d)}) }
+	}
+	// Update needs at least one field; an edit that kept rating and skin
+	// type still touches the product.
+	return append(ups, firestore.Update{Path: "rating.updatedAt", Value: firestore.ServerTimestamp})
+}
+
+func productRating(w http.ResponseWriter, r *http.Request) {
+	doc, err := fs.Collection("products").Doc(chi.URLParam(r, "id")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var p Product
+	if err := doc.DataTo(&p); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRatingSummary(p.Rating))
+}
+
+/* ────── helpers ─────────────────────────────────────────────────────────── */
+
+// decodeReview validates the body; a linked post must be the reviewer's own
+// live, public post.
+func decodeReview(w http.ResponseWriter, r *http.Request, uid string) (reviewRequest, bool) {
+	var req reviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return req, false
+	}
+	req.Title, req.Text = strings.TrimSpace(req.Title), strings.TrimSpace(req.Text)
+	var msg string
+	switch {
+	case req.Rating < 1 || req.Rating > 5:
+		msg = "rating must be 1..5"
+	case !skinTypes[req.SkinType]:
+		msg = "skinType must be dry, oily, combination, normal or sensitive"
+	case len([]rune(req.Title)) > maxReviewTitle:
+		msg = fmt.Sprintf("title longer than %d characters", maxReviewTitle)
+	case req.Text == "":
+		msg = "text required"
+	case len([]rune(req.Text)) > maxReviewText:
+		msg = fmt.Sprintf("text longer than %d characters", maxReviewText)
+	}
+	if msg == "" { req.Pros, msg = cleanList("pros", req.Pros) }
+	if msg == "" { req.Cons, msg = cleanList("cons", req.Cons) }
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return req, false
+	}
+
+	if req.PostID != "" {
+		doc, err := fs.Collection("posts").Doc(req.PostID).Get(r.Context())
+		if err != nil {
+			http.Error(w, "unknown post", http.StatusBadRequest)
+			return req, false
+		}
+		post, err := models.PostFromDoc(doc)
+		if err != nil || post.AuthorID != uid || !post.IsLive() || !post.IsPublic() {
+			http.Error(w, "postID must be your own published public post", http.StatusBadRequest)
+			return req, false
+		}
+	}
+	return req, true
+}
+
+func cleanList(field string, in []string) ([]string, string) {
+	out := []string{}
+	for _, s := range in {
+		if s = strings.TrimSpace(s); s == "" { continue }
+		if len([]rune(s)) > maxProConLen { return nil, fmt.Sprintf("%s: entries up to %d characters", field, maxProConLen) }
+		out = append(out, s)
+	}
+	if len(out) > maxProsCons { return nil, fmt.Sprintf("at most %d %s", maxProsCons, field) }
+	return out, ""
+}
+
+// reviewResponses resolves authors, linked posts and the viewer's helpful
+// votes.
+func reviewResponses(c context.Context, uid string, reviews []Review) []reviewResponse {
+	uids := make([]string, 0, len(reviews))
+	var postRefs []*firestore.DocumentRef
+	for _, rev := range reviews {
+		uids = append(uids, rev.AuthorID)
+		if rev.PostID != "" { postRefs = append(postRefs, fs.Collection("posts").Doc(rev.PostID)) }
+	}
+	authors, _ := models.LoadAuthors(c, fs, uids)
+
+	posts := map[string]models.PostResponse{}
+	if len(postRefs) > 0 {
+		if docs, err := fs.GetAll(c, postRefs); err == nil {
+			var live []models.Post
+			for _, d := range docs {
+				if !d.Exists() { continue }
+				if p, err := models.PostFromDoc(d); err == nil && p.IsLive() && p.IsPublic() { live = append(live, p) }
+			}
+			for _, p := range models.PostResponses(c, fs, live) { posts[p.ID] = p }
+		}
+	}
+
+	var votes []*firestore.DocumentSnapshot
+	if uid != "" && len(reviews) > 0 {
+		refs := make([]*firestore.DocumentRef, 0, len(reviews))
+		for _, rev := range reviews { refs = append(refs, reviewsCol(rev.ProductID).Doc(rev.ID).Collection("helpful").Doc(uid)) }
+		votes, _ = fs.GetAll(c, refs)
+	}
+
+	out := make([]reviewResponse, 0, len(reviews))
+	for i, rev := range reviews {
+		resp := reviewResponse{Review: rev, Author: authors[rev.AuthorID],
+			CreatedAt: models.FormatTime(rev.CreatedAt), UpdatedAt: models.FormatTime(rev.UpdatedAt)}
+		if p, ok := posts[rev.PostID]; ok { resp.Post = &p }
+		if i < len(votes) { resp.HelpfulByMe = votes[i].Exists() }
+		out = append(out, resp)
+	}
+	return out
+}
@@ -15,6 +15,9 @@ import (
 	"github.com/go-redis/redis/v8"
 
 	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
 )
 
 var (
@@ -34,15 +37,28 @@ func main() {
 	var err error
 	if fs, err = firestore.NewClient(ctx, projectID); err != nil { log.Fatal(err) }
 	if err = auth.Init(ctx); err != nil { log.Fatal(err) }
+	if publicBaseURL == "" { log.Fatal("PUBLIC_BASE_URL must be set, syndication feeds link to it") }
+	pushAuth, err := auth.Invoker() // the push subscription's OIDC token
+	if err != nil { log.Fatalf("pubsub push: %v", err) }
 	if redisAddr != "" {
 		rdb = redis.NewClient(&redis.Options{Addr: redisAddr})
 	}
+	// media URL signing – same store setup as media-service; without it
+	// every post would go out without media
+	store, err := blobstore.FromEnv(ctx)
+	if err != nil { log.Fatalf("storage: %v", err) }
+	if !store.CanSign() { log.Fatal("storage: no signing credentials") }
+	signing.Init(store)
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
 	r.Get("/feed/global", globalFeed)
 	r.Get("/feed/following", followingFeed)
+	r.Get("/users/{id}/feed.json", jsonProfileFeed)
+	r.Get("/users/{id}/feed.atom", atomProfileFeed)
+	r.Get("/feed/media/{id}", feedMedia)
+	r.With(pushAuth).Post("/pubsub", handlePostEvent)
 	r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("feed-svc OK")) })
 
 	log.Printf("feed-service listening on :%s", port)
@@ -50,15 +66,24 @@ func main() {
 }
 
 func globalFeed(w http.ResponseWriter, r *http.Request) {
-	cacheKey := "feed:global"
-	if maybeServeCache(w, r, cacheKey) { return }
+	uid, _ := auth.VerifyFirebaseToken(r.Context(), r) // optional, only for likedByMe
+
+	// The global feed is cached for everyone, so it only carries public posts;
+	// restricted ones reach their audience through the following feed.
 
-	docs, _ := fs.Collection("posts").OrderBy("timestamp", firestore.Desc).
-		Limit(50).Documents(r.Context()).GetAll()
+	cacheKey := "feed:global"
+	if maybeServeCache(w, r, cacheKey, uid) { return }
 
-	posts := make([]map[string]any, 0, len(docs))
-	for _, d := range docs { posts = append(posts, d.Data()) }
-	respondAndCache(w, r, cacheKey, posts, 5*time.Minute)
+	q := fs.Collection("posts").OrderBy("timestamp", firestore.Desc)
+	posts, err := collectPosts(r.Context(), q, 50, func(batch []models.Post) []models.Post {
+		out := batch[:0]
+		for _, p := range batch {
+			if p.IsLive() && p.IsPublic() { out = append(out, p) }
+		}
+		return out
+	})
+	if err != nil { http.Error(w, "db read err", 500); return }
+	respondAndCache(w, r, cacheKey, uid, models.PostResponses(r.Context(), fs, posts), 5*time.Minute)
 }
 
 func followingFeed(w http.ResponseWriter, r *http.Request) {
@@ -66,53 +91,109 @@ func followingFeed(w http.ResponseWriter, r *http.Request) {
 	if err != nil { http.Error(w, "unauth", 401); return }
 
 	cacheKey := "feed:user:" + uid
-	if maybeServeCache(w, r, cacheKey) { return }
+	if maybeServeCache(w, r, cacheKey, uid) { return }
 
 	// gather following
 	followDocs, _ := fs.Collection("users").Doc(uid).Collection("following").
 		Documents(r.Context()).GetAll()
-	if len(followDocs) == 0 { respondAndCache(w, r, cacheKey, []any{}, 2*time.Minute); return }
+	if len(followDocs) == 0 { respondAndCache(w, r, cacheKey, uid, []models.PostResponse{}, 2*time.Minute); return }
 
 	ids := make([]string, 0, len(followDocs))
 	for _, d := range followDocs { ids = append(ids, d.Ref.ID) }
 
-	var posts []map[string]any
+	var posts []models.Post
 	for _, chunk := range chunks(ids, 10) {
-		q := fs.Collection("posts").Where("authorID", "in", chunk).
-			OrderBy("timestamp", firestore.Desc).Limit(50)
-		iter := q.Documents(r.Context())
-		for {
-			doc, err := iter.Next()
-			if err != nil { break }
-			posts = append(posts, doc.Data())
-		}
+		q := fs.Collection("posts").Where("authorID", "in", chunk).OrderBy("timestamp", firestore.Desc)
+		found, err := collectPosts(r.Context(), q, 50, func(batch []models.Post) []models.Post {
+			live := batch[:0]
+			for _, p := range batch {
+				if p.IsLive() { live = append(live, p) }
+			}
+			return models.FilterVisible(r.Context(), fs, uid, live) // per-user cache, so filtering before caching is fine
+		})
+		if err != nil { http.Error(w, "db read err", 500); return }
+		posts = append(posts, found...)
 	}
-	sort.Slice(posts, func(i, j int) bool {
-		ti, _ := posts[i]["timestamp"].(time.Time)
-		tj, _ := posts[j]["timestamp"].(time.Time)
-		return ti.After(tj)
-	})
+	sort.Slice(posts, func(i, j int) bool { return posts[i].Timestamp.After(posts[j].Timestamp) })
 	if len(posts) > 100 { posts = posts[:100] }
 
-	respondAndCache(w, r, cacheKey, posts, 2*time.Minute)
+	respondAndCache(w, r, cacheKey, uid, models.PostResponses(r.Context(), fs, posts), 2*time.Minute)
+}
+
+type pushMsg struct {
+	Message struct {
+		Data       string            `json:"data"`
+		Attributes map[string]string `json:"attributes"`
+	} `json:"message"`
+}
+
+// handlePostEvent is the push endpoint for the post-events topic. Deleted
+// posts and visibility changes are reflected in the cached global feed
+// right away; per-user feeds expire on their own within minutes.
+func handlePostEvent(w http.ResponseWriter, r *http.Request) {
+	var m pushMsg
+	_ = json.NewDecoder(r.Body).Decode(&m)
+
+	switch m.Message.Attributes["type"] {
+	case "POST_DELETED", "POST_PUBLISHED", "POST_VISIBILITY_CHANGED": // scheduled posts must not wait for the TTL
+		if rdb != nil { _ = rdb.Del(ctx, "feed:global").Err() }
+	}
+	w.WriteHeader(200)
 }
 
 // ——— helpers ————————————————————
 
-func maybeServeCache(w http.ResponseWriter, r *http.Request, key string) bool {
+// The cache holds the caller-independent feed; likedByMe is filled in per
+// request on the way out.
+
+func maybeServeCache(w http.ResponseWriter, r *http.Request, key, uid string) bool {
 	if rdb == nil { return false }
-	val, err := rdb.Get(ctx, key).Result()
+	val, err := rdb.Get(ctx, key).Bytes()
 	if err != nil { return false }
-	w.Header().Set("Content-Type", "application/json")
-	w.Write([]byte(val))
+	var posts []models.PostResponse
+	if err := json.Unmarshal(val, &posts); err != nil { return false }
+	writePosts(w, r, uid, posts)
 	return true
 }
 
-func respondAndCache(w http.ResponseWriter, r *http.Request, key string, data any, ttl time.Duration) {
-	b, _ := json.Marshal(data)
+func respondAndCache(w http.ResponseWriter, r *http.Request, key, uid string, posts []models.PostResponse, ttl time.Duration) {
+	if rdb != nil {
+		b, _ := json.Marshal(posts)
+		_ = rdb.Set(ctx, key, b, ttl).Err()
+	}
+	writePosts(w, r, uid, posts)
+}
+
+func writePosts(w http.ResponseWriter, r *http.Request, uid string, posts []models.PostResponse) {
+	models.Personalize(r.Context(), fs, uid, posts)
+	b, _ := json.Marshal(posts)
 	w.Header().Set("Content-Type", "application/json")
 	w.Write(b)
-	if rdb != nil { _ = rdb.Set(ctx, key, b, ttl).Err() }
+}
+
+// maxFeedBatches bounds the queries collectPosts makes for one feed.
+const maxFeedBatches = 5
+
+// collectPosts reads q in batches of n and keeps what keep lets through,
+// until n posts are kept or the posts run out. Pending, draft and restricted
+// posts are only recognised after reading, so a single batch could come out
+// nearly empty.
+func collectPosts(c context.Context, q firestore.Query, n int, keep func([]models.Post) []models.Post) ([]models.Post, error) {
+	var out []models.Post
+	q = q.Limit(n)
+	for batch := 0; batch < maxFeedBatches && len(out) < n; batch++ {
+		docs, err := q.Documents(c).GetAll()
+		if err != nil { return nil, err }
+		posts := make([]models.Post, 0, len(docs))
+		for _, d := range docs {
+			if p, err := models.PostFromDoc(d); err == nil { posts = append(posts, p) }
+		}
+		out = append(out, keep(posts)...)
+		if len(docs) < n { break }
+		q = q.StartAfter(docs[len(docs)-1])
+	}
+	if len(out) > n { out = out[:n] }
+	return out, nil
 }
 
 func chunks(s []string, n int) [][]string {
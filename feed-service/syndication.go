@@ -0,0 +1,285 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+// Public profile feeds (JSON Feed 1.1 / Atom) for syndication to blogs and
+// readers. No auth; only public posts are ever included. Readers keep
+// entries for weeks, so media is linked through feedMedia, which signs a
+// fresh URL on every request, rather than with signed URLs that expire.
+
+// publicBaseURL is where feeds and their links point; required, since the
+// request's Host header is the caller's to choose and the feeds are cached.
+var publicBaseURL = strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/") // e.g. "https://cosmeticsocial.app"
+
+const (
+	syndicationLimit  = 50
+	syndicationMaxAge = 5 * time.Minute
+
+	// maxSyndicationBatches bounds the queries behind one feed.
+	maxSyndicationBatches = 5
+)
+
+type profileFeed struct {
+	base    string
+	selfURL string
+	author  models.AuthorSummary
+	items   []models.Post
+	updated time.Time
+}
+
+func jsonProfileFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadProfileFeed(w, r)
+	if !ok { return }
+
+	out := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.title(),
+		HomePageURL: f.profileURL(),
+		FeedURL:     f.selfURL,
+		Authors:     []jsonFeedAuthor{{Name: f.authorName(), URL: f.profileURL(), Avatar: f.author.AvatarURL}},
+		Items:       []jsonFeedItem{},
+	}
+	for _, p := range f.items {
+		item := jsonFeedItem{
+			ID:            p.ID,
+			URL:           f.postURL(p.ID),
+			ContentText:   p.Caption,
+			Image:         f.imageURL(p),
+			DatePublished: models.FormatTime(p.Timestamp),
+			DateModified:  models.FormatTime(p.EditedAt),
+		}
+		if m := mediaMIME(p); m != "" {
+			item.Attachments = []jsonFeedAttachment{{URL: f.mediaURL(p.ID), MimeType: m}}
+		}
+		out.Items = append(out.Items, item)
+	}
+	b, _ := json.Marshal(out)
+	writeFeed(w, r, "application/feed+json", b, f.updated)
+}
+
+func atomProfileFeed(w http.ResponseWriter, r *http.Request) {
+	f, ok := loadProfileFeed(w, r)
+	if !ok { return }
+
+	out := atomFeed{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		ID:      f.profileURL(),
+		Title:   f.title(),
+		Updated: models.FormatTime(f.updated),
+		Author:  atomAuthor{Name: f.authorName(), URI: f.profileURL()},
+		Icon:    f.author.AvatarURL,
+		Links: []atomLink{
+			{Rel: "self", Href: f.selfURL, Type: "application/atom+xml"},
+			{Rel: "alternate", Href: f.profileURL(), Type: "text/html"},
+		},
+	}
+	if out.Updated == "" { out.Updated = models.FormatTime(time.Unix(0, 0)) }
+	for _, p := range f.items {
+		e := atomEntry{
+			ID:        f.postURL(p.ID),
+			Title:     entryTitle(p.Caption),
+			Updated:   models.FormatTime(lastModified(p)),
+			Published: models.FormatTime(p.Timestamp),
+			Summary:   p.Caption,
+			Links:     []atomLink{{Rel: "alternate", Href: f.postURL(p.ID), Type: "text/html"}},
+		}
+		if m := mediaMIME(p); m != "" {
+			e.Links = append(e.Links, atomLink{Rel: "enclosure", Href: f.mediaURL(p.ID), Type: m})
+		}
+		out.Entries = append(out.Entries, e)
+	}
+	b, _ := xml.Marshal(out)
+	writeFeed(w, r, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), b...), f.updated)
+}
+
+// loadProfileFeed gathers the author and their newest public posts.
+func loadProfileFeed(w http.ResponseWriter, r *http.Request) (*profileFeed, bool) {
+	uid := chi.URLParam(r, "id")
+	authors, err := models.LoadAuthors(r.Context(), fs, []string{uid})
+	if err != nil { http.Error(w, "db read err", 500); return nil, false }
+	if authors[uid].Username == "" { http.Error(w, "not found", 404); return nil, false }
+
+	// drafts, scheduled and restricted posts are skipped after reading, so
+	// batches are read until the feed is full, as for posts by product
+	f := &profileFeed{base: publicBaseURL, selfURL: publicBaseURL + r.URL.Path, author: authors[uid]}
+	q := fs.Collection("posts").Where("authorID", "==", uid).
+		OrderBy("timestamp", firestore.Desc).Limit(syndicationLimit)
+	for batch := 0; batch < maxSyndicationBatches && len(f.items) < syndicationLimit; batch++ {
+		docs, err := q.Documents(r.Context()).GetAll()
+		if err != nil { http.Error(w, "db read err", 500); return nil, false }
+		for _, d := range docs {
+			p, err := models.PostFromDoc(d)
+			if err != nil || !p.IsLive() || !p.IsPublic() { continue }
+			if t := lastModified(p); t.After(f.updated) { f.updated = t }
+			f.items = append(f.items, p)
+			if len(f.items) == syndicationLimit { break }
+		}
+		if len(docs) < syndicationLimit { break } // no more posts
+		q = q.StartAfter(docs[len(docs)-1])
+	}
+	return f, true
+}
+
+// writeFeed sets caching headers and honours conditional requests.
+func writeFeed(w http.ResponseWriter, r *http.Request, contentType string, body []byte, updated time.Time) {
+	sum := sha1.Sum(body)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(syndicationMaxAge.Seconds())))
+	w.Header().Set("ETag", etag)
+	if !updated.IsZero() { w.Header().Set("Last-Modified", updated.UTC().Format(http.TimeFormat)) }
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		if match == etag { w.WriteHeader(http.StatusNotModified); return }
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !updated.IsZero() && !updated.Truncate(time.Second).After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+func (f *profileFeed) authorName() string { return "@" + f.author.Username }
+func (f *profileFeed) title() string      { return f.authorName() + " on CosmeticSocial" }
+func (f *profileFeed) profileURL() string { return f.base + "/u/" + f.author.Username }
+func (f *profileFeed) postURL(id string) string { return f.base + "/p/" + id }
+func (f *profileFeed) mediaURL(id string) string { return f.base + "/feed/media/" + id }
+
+// lastModified is when p last changed: its edit time, if any, else its
+// creation time.
+func lastModified(p models.Post) time.Time {
+	if p.EditedAt.After(p.Timestamp) { return p.EditedAt }
+	return p.Timestamp
+}
+
+// imageURL is the preview image of p: its thumbnail, or the image itself.
+func (f *profileFeed) imageURL(p models.Post) string {
+	items := p.Items()
+	switch {
+	case len(items) == 0:
+		return ""
+	case items[0].ThumbnailPath != "":
+		return f.mediaURL(p.ID) + "?thumbnail=1"
+	case items[0].Type == "image" && items[0].DisplayPath() != "":
+		return f.mediaURL(p.ID)
+	}
+	return ""
+}
+
+// mediaMIME is the type of the object feedMedia serves for p: the cover's
+// display rendition (a JPEG variant for images), not the upload. "" when
+// there is nothing to serve yet.
+func mediaMIME(p models.Post) string {
+	items := p.Items()
+	if len(items) == 0 || items[0].DisplayPath() == "" { return "" }
+	if t := mime.TypeByExtension(path.Ext(items[0].DisplayPath())); t != "" { return t }
+	if items[0].Type == "video" { return "video/mp4" }
+	return "image/jpeg"
+}
+
+// feedMedia redirects to a freshly signed URL for the cover of a public
+// post (?thumbnail=1: its thumbnail). Feeds link here so their entries
+// never go stale; the redirect is cached for less than the URL lives.
+func feedMedia(w http.ResponseWriter, r *http.Request) {
+	doc, err := fs.Collection("posts").Doc(chi.URLParam(r, "id")).Get(r.Context())
+	if err != nil { http.Error(w, "not found", 404); return }
+	p, err := models.PostFromDoc(doc)
+	if err != nil || !p.IsLive() || !p.IsPublic() || len(p.Items()) == 0 { http.Error(w, "not found", 404); return }
+
+	object := p.Items()[0].DisplayPath()
+	if r.URL.Query().Get("thumbnail") != "" { object = p.Items()[0].ThumbnailPath }
+	if object == "" { http.Error(w, "not found", 404); return }
+	url, err := signing.ReadURL(object)
+	if err != nil { http.Error(w, "signed-url err", 500); return }
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(syndicationMaxAge.Seconds())))
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func entryTitle(caption string) string {
+	t := strings.TrimSpace(strings.SplitN(caption, "\n", 2)[0])
+	if r := []rune(t); len(r) > 80 { t = string(r[:80]) + "…" }
+	if t == "" { t = "New post" }
+	return t
+}
+
+// ——— wire formats ————————————————————
+
+type jsonFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Authors     []jsonFeedAuthor `json:"authors"`
+	Items       []jsonFeedItem   `json:"items"`
+}
+
+type jsonFeedAuthor struct {
+	Name   string `json:"name"`
+	URL    string `json:"url,omitempty"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url"`
+	ContentText   string               `json:"content_text"`
+	Image         string               `json:"image,omitempty"`
+	DatePublished string               `json:"date_published,omitempty"`
+	DateModified  string               `json:"date_modified,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAttachment struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Icon    string      `xml:"icon,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     string     `xml:"title"`
+	Updated   string     `xml:"updated"`
+	Published string     `xml:"published"`
+	Summary   string     `xml:"summary,omitempty"`
+	Links     []atomLink `xml:"link"`
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+/* ────── before/after posts ───────────────────────────────────────────────── */
+
+// A before/after post is created like any other with kind "before_after"
+// and exactly two image items, before first. Video-processing crops both
+// to a shared aspect ratio; responses carry them as a beforeAfter pair.
+
+// validatePair checks the media of a before/after post.
+func validatePair(media []mediaRequest) string {
+	if len(media) != 2 { return "before_after posts need exactly two media items, before and after" }
+	for i, m := range media {
+		if m.MediaType != "image" { return fmt.Sprintf("media %d: before_after posts take images only", i) }
+	}
+	return ""
+}
+
+// cleanSteps trims notes, drops empty steps and checks the limits and that
+// every product exists. msg is a client error; err a failed lookup.
+func cleanSteps(c context.Context, steps []models.Step) (out []models.Step, msg string, err error) {
+	out = []models.Step{}
+	var ids []string
+	for i, s := range steps {
+		s.Note = strings.TrimSpace(s.Note)
+		if s.Note == "" && len(s.ProductIDs) == 0 { continue }
+		if utf8.RuneCountInString(s.Note) > models.MaxStepNoteLen {
+			return nil, fmt.Sprintf("step %d: note longer than %d characters", i, models.MaxStepNoteLen), nil
+		}
+		if len(s.ProductIDs) > models.MaxStepProducts {
+			return nil, fmt.Sprintf("step %d: at most %d products", i, models.MaxStepProducts), nil
+		}
+		s.ProductIDs = distinctIDs(s.ProductIDs)
+		ids = append(ids, s.ProductIDs...)
+		out = append(out, s)
+	}
+	if len(out) > models.MaxSteps { return nil, fmt.Sprintf("at most %d steps", models.MaxSteps), nil }
+
+	products, err := models.LoadProducts(c, fs, ids)
+	if err != nil { return nil, "", err }
+	for _, id := range ids {
+		if _, ok := products[id]; !ok { return nil, "unknown product " + id, nil }
+	}
+	return out, "", nil
+}
+
+// distinctIDs drops blanks and repeats from ids, keeping their order.
+func distinctIDs(ids []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id == "" || seen[id] { continue }
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
+
+// stepsText is what the steps are moderated as.
+func stepsText(steps []models.Step) string {
+	notes := make([]string, 0, len(steps))
+	for _, s := range steps { notes = append(notes, s.Note) }
+	return strings.Join(notes, "\n")
+}
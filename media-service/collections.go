@@ -0,0 +1,537 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── saved collections ────────────────────────────────────────────────── */
+
+// Collections are named folders of saved posts at users/{uid}/collections/{cid}
+// with one item doc per post under …/posts/{postID}. A shared collection
+// gets a random token; sharedCollections/{token} maps it back to its owner.
+
+const (
+	maxCollections       = 100
+	maxCollectionNameLen = 60
+)
+
+// publicBaseURL prefixes share links when set, e.g. "https://cosmeticsocial.app".
+var publicBaseURL = strings.TrimSuffix(os.Getenv("PUBLIC_BASE_URL"), "/")
+
+func collectionsCol(uid string) *firestore.CollectionRef {
+	return fs.Collection("users").Doc(uid).Collection("collections")
+}
+
+type Collection struct {
+	ID          string    `firestore:"-"`
+	Name        string    `firestore:"name"`
+	CoverPostID string    `firestore:"coverPostID,omitempty"` // chosen cover
+	LastPostID  string    `firestore:"lastPostID,omitempty"`  // fallback cover
+	Position    int       `firestore:"position"`
+	PostCount   int64     `firestore:"postCount"`
+	ShareToken  string    `firestore:"shareToken,omitempty"`
+	CreatedAt   time.Time `firestore:"createdAt"`
+	UpdatedAt   time.Time `firestore:"updatedAt"`
+}
+
+type collectionItem struct {
+	PostID  string    `firestore:"postID"`
+	AddedAt time.Time `firestore:"addedAt"`
+}
+
+type sharedCollectionDoc struct {
+	OwnerID      string `firestore:"ownerID"`
+	CollectionID string `firestore:"collectionID"`
+}
+
+type collectionResponse struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	CoverPostID string    `json:"coverPostID,omitempty"`
+	CoverURL    string    `json:"coverURL,omitempty"`
+	Position    int       `json:"position"`
+	PostCount   int64     `json:"postCount"`
+	ShareURL    string    `json:"shareURL,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+type collectionRequest struct {
+	Name        *string `json:"name"`
+	CoverPostID *string `json:"coverPostID"` // must be in the collection; "" resets
+}
+
+func collectionFromDoc(d *firestore.DocumentSnapshot) (Collection, error) {
+	var col Collection
+	err := d.DataTo(&col)
+	col.ID = d.Ref.ID
+	return col, err
+}
+
+func shareURL(token string) string {
+	if token == "" { return "" }
+	return publicBaseURL + "/shared/collections/" + token
+}
+
+// collectionResponses resolves covers: the chosen cover post, else the most
+// recently added one. Covers whose post is gone, no longer live or hidden
+// from viewer are left empty. viewer is "" for the public shared view,
+// which also leaves out the share link.
+func collectionResponses(c context.Context, viewer string, cols []Collection) []collectionResponse {
+	out := make([]collectionResponse, 0, len(cols))
+	var ids []string
+	for _, col := range cols {
+		resp := collectionResponse{
+			ID: col.ID, Name: col.Name, CoverPostID: col.CoverPostID, Position: col.Position,
+			PostCount: col.PostCount, ShareURL: shareURL(col.ShareToken),
+			CreatedAt: col.CreatedAt, UpdatedAt: col.UpdatedAt,
+		}
+		if viewer == "" { resp.ShareURL = "" }
+		out = append(out, resp)
+		if id := coverOf(col); id != "" { ids = append(ids, id) }
+	}
+
+	covers := map[string]string{}
+	for _, p := range models.FilterVisible(c, fs, viewer, livePosts(c, ids)) {
+		resp := models.NewPostResponse(p, models.AuthorSummary{})
+		covers[p.ID] = resp.ThumbnailURL
+		if covers[p.ID] == "" { covers[p.ID] = resp.MediaURL }
+	}
+	for i, col := range cols { out[i].CoverURL = covers[coverOf(col)] }
+	return out
+}
+
+func coverOf(col Collection) string {
+	if col.CoverPostID != "" { return col.CoverPostID }
+	return col.LastPostID
+}
+
+// detachUpdates are the collection updates for taking postID out of it.
+func detachUpdates(doc *firestore.DocumentSnapshot, postID string) []firestore.Update {
+	ups := []firestore.Update{
+		{Path: "postCount", Value: firestore.Increment(-1)},
+		{Path: "updatedAt", Value: firestore.ServerTimestamp},
+	}
+	col, err := collectionFromDoc(doc)
+	if err != nil { return ups }
+	if col.CoverPostID == postID { ups = append(ups, firestore.Update{Path: "coverPostID", Value: firestore.Delete}) }
+	if col.LastPostID == postID { ups = append(ups, firestore.Update{Path: "lastPostID", Value: firestore.Delete}) }
+	return ups
+}
+
+// loadOwnCollection fetches the caller's collection {cid}.
+func loadOwnCollection(w http.ResponseWriter, r *http.Request) (string, Collection, bool) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return "", Collection{}, false
+	}
+	doc, err := collectionsCol(uid).Doc(chi.URLParam(r, "cid")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return "", Collection{}, false
+	}
+	col, err := collectionFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return "", Collection{}, false
+	}
+	return uid, col, true
+}
+
+func listCollections(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	docs, err := collectionsCol(uid).OrderBy("position", firestore.Asc).Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	cols := make([]Collection, 0, len(docs))
+	for _, d := range docs {
+		if col, err := collectionFromDoc(d); err == nil { cols = append(cols, col) }
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(collectionResponses(r.Context(), uid, cols))
+}
+
+// createCollection appends a new, empty collection after the existing ones.
+func createCollection(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	var req collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if req.Name == nil {
+		http.Error(w, "name required", http.StatusBadRequest)
+		return
+	}
+	name, msg := cleanCollectionName(*req.Name)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	ref := collectionsCol(uid).NewDoc()
+	now := time.Now().UTC()
+	col := Collection{ID: ref.ID, Name: name, CreatedAt: now, UpdatedAt: now}
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		existing, err := tx.Documents(collectionsCol(uid).Select("position")).GetAll()
+		if err != nil { return err }
+		if len(existing) >= maxCollections {
+			return status.Errorf(codes.FailedPrecondition, "at most %d collections", maxCollections)
+		}
+		for _, d := range existing { // deletes leave gaps, so go past the highest
+			if n, _ := d.Data()["position"].(int64); int(n) >= col.Position { col.Position = int(n) + 1 }
+		}
+		return tx.Create(ref, col)
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(collectionResponses(r.Context(), uid, []Collection{col})[0])
+}
+
+// editCollection renames a collection or picks its cover.
+func editCollection(w http.ResponseWriter, r *http.Request) {
+	uid, col, ok := loadOwnCollection(w, r)
+	if !ok { return }
+	var req collectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	ref := collectionsCol(uid).Doc(col.ID)
+	var updates []firestore.Update
+	if req.Name != nil {
+		name, msg := cleanCollectionName(*req.Name)
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "name", Value: name})
+	}
+	if req.CoverPostID != nil {
+		if *req.CoverPostID == "" {
+			updates = append(updates, firestore.Update{Path: "coverPostID", Value: firestore.Delete})
+		} else {
+			if _, err := ref.Collection("posts").Doc(*req.CoverPostID).Get(r.Context()); err != nil {
+				http.Error(w, "cover post not in collection", http.StatusBadRequest)
+				return
+			}
+			updates = append(updates, firestore.Update{Path: "coverPostID", Value: *req.CoverPostID})
+		}
+	}
+	if len(updates) == 0 {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	updates = append(updates, firestore.Update{Path: "updatedAt", Value: firestore.ServerTimestamp})
+	if _, err := ref.Update(r.Context(), updates); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+
+	doc, err := ref.Get(r.Context())
+	if err == nil { col, err = collectionFromDoc(doc) }
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(collectionResponses(r.Context(), uid, []Collection{col})[0])
+}
+
+func cleanCollectionName(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if s == "" { return "", "name required" }
+	if utf8.RuneCountInString(s) > maxCollectionNameLen { return "", "name too long" }
+	return s, ""
+}
+
+// reorderCollections takes every one of the caller's collection IDs in the
+// desired order.
+func reorderCollections(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		docs, err := tx.Documents(collectionsCol(uid).Select()).GetAll()
+		if err != nil { return err }
+		if len(req.IDs) != len(docs) {
+			return status.Error(codes.InvalidArgument, "ids must list every collection exactly once")
+		}
+		have := map[string]bool{}
+		for _, d := range docs { have[d.Ref.ID] = true }
+		for i, id := range req.IDs {
+			if !have[id] {
+				return status.Error(codes.InvalidArgument, "ids must list every collection exactly once")
+			}
+			delete(have, id)
+			if err := tx.Update(collectionsCol(uid).Doc(id), []firestore.Update{{Path: "position", Value: i}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// dropCollection deletes a collection. Its posts stay saved.
+func dropCollection(w http.ResponseWriter, r *http.Request) {
+	uid, col, ok := loadOwnCollection(w, r)
+	if !ok { return }
+	ref := collectionsCol(uid).Doc(col.ID)
+
+	for {
+		items, err := ref.Collection("posts").Limit(200).Documents(r.Context()).GetAll()
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		if len(items) == 0 { break }
+
+		saves := make([]*firestore.DocumentRef, 0, len(items))
+		for _, it := range items { saves = append(saves, models.SaveRef(fs, uid, it.Ref.ID)) }
+		docs, err := fs.GetAll(r.Context(), saves)
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		b := fs.Batch()
+		for i, it := range items {
+			if docs[i].Exists() {
+				b.Update(docs[i].Ref, []firestore.Update{{Path: "collections", Value: firestore.ArrayRemove(col.ID)}})
+			}
+			b.Delete(it.Ref)
+		}
+		if _, err := b.Commit(r.Context()); err != nil {
+			http.Error(w, "db write err", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	b := fs.Batch()
+	b.Delete(ref)
+	if col.ShareToken != "" { b.Delete(fs.Collection("sharedCollections").Doc(col.ShareToken)) }
+	if _, err := b.Commit(r.Context()); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addToCollection files a post under a collection, saving it first if needed.
+func addToCollection(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	save, err := saveTx(r.Context(), uid, chi.URLParam(r, "postID"), chi.URLParam(r, "cid"))
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saveResponse{Saved: true, Collections: save.Collections})
+}
+
+// removeFromCollection takes a post out of a collection; it stays saved.
+func removeFromCollection(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	cid, postID := chi.URLParam(r, "cid"), chi.URLParam(r, "postID")
+	saveRef := models.SaveRef(fs, uid, postID)
+	colRef := collectionsCol(uid).Doc(cid)
+
+	var save saveDoc
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		sdoc, err := tx.Get(saveRef)
+		if err != nil { return err }
+		if err := sdoc.DataTo(&save); err != nil { return err }
+		cdoc, err := tx.Get(colRef)
+		if err != nil { return err }
+
+		kept := save.Collections[:0]
+		for _, id := range save.Collections {
+			if id != cid { kept = append(kept, id) }
+		}
+		if len(kept) == len(save.Collections) { return status.Error(codes.NotFound, "not in collection") }
+		save.Collections = kept
+
+		if err := tx.Set(saveRef, save); err != nil { return err }
+		if err := tx.Delete(colRef.Collection("posts").Doc(postID)); err != nil { return err }
+		return tx.Update(colRef, detachUpdates(cdoc, postID))
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saveResponse{Saved: true, Collections: save.Collections})
+}
+
+// collectionPosts pages through one of the caller's collections, most
+// recently added first.
+func collectionPosts(w http.ResponseWriter, r *http.Request) {
+	uid, col, ok := loadOwnCollection(w, r)
+	if !ok { return }
+	page, ok := collectionPage(w, r, uid, col.ID, uid)
+	if !ok { return }
+	models.Personalize(r.Context(), fs, uid, page.Items)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// collectionPage lists the posts of uid's collection cid that viewer may
+// see; the shared view passes no viewer and so only gets public posts.
+func collectionPage(w http.ResponseWriter, r *http.Request, uid, cid, viewer string) (paging.Page[models.PostResponse], bool) {
+	items := collectionsCol(uid).Doc(cid).Collection("posts")
+	limit := paging.Limit(r, 20, 50)
+	q, err := paging.StartAfter(r, items, items.OrderBy("addedAt", firestore.Desc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return paging.Page[models.PostResponse]{}, false
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return paging.Page[models.PostResponse]{}, false
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs { ids = append(ids, d.Ref.ID) }
+	posts := models.FilterVisible(r.Context(), fs, viewer, livePosts(r.Context(), ids))
+	page := paging.Page[models.PostResponse]{Items: models.PostResponses(r.Context(), fs, posts)}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	return page, true
+}
+
+/* ────── sharing ──────────────────────────────────────────────────────────── */
+
+// shareCollection makes a collection viewable by anyone with its link.
+// Sharing an already shared collection returns the existing link.
+func shareCollection(w http.ResponseWriter, r *http.Request) {
+	uid, col, ok := loadOwnCollection(w, r)
+	if !ok { return }
+
+	if col.ShareToken == "" {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			http.Error(w, "token err", http.StatusInternalServerError)
+			return
+		}
+		col.ShareToken = hex.EncodeToString(buf)
+
+		b := fs.Batch()
+		b.Create(fs.Collection("sharedCollections").Doc(col.ShareToken), sharedCollectionDoc{OwnerID: uid, CollectionID: col.ID})
+		b.Update(collectionsCol(uid).Doc(col.ID), []firestore.Update{{Path: "shareToken", Value: col.ShareToken}})
+		if _, err := b.Commit(r.Context()); err != nil {
+			http.Error(w, "db write err", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": col.ShareToken, "shareURL": shareURL(col.ShareToken)})
+}
+
+// unshareCollection revokes the link; sharing again issues a new one.
+func unshareCollection(w http.ResponseWriter, r *http.Request) {
+	uid, col, ok := loadOwnCollection(w, r)
+	if !ok { return }
+	if col.ShareToken != "" {
+		b := fs.Batch()
+		b.Delete(fs.Collection("sharedCollections").Doc(col.ShareToken))
+		b.Update(collectionsCol(uid).Doc(col.ID), []firestore.Update{{Path: "shareToken", Value: firestore.Delete}})
+		if _, err := b.Commit(r.Context()); err != nil {
+			http.Error(w, "db write err", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type sharedCollectionResponse struct {
+	Collection collectionResponse               `json:"collection"`
+	Owner      models.AuthorSummary             `json:"owner"`
+	Posts      paging.Page[models.PostResponse] `json:"posts"`
+}
+
+// sharedCollection is the public view of a shared collection. No sign-in is
+// needed, so only public posts are listed.
+func sharedCollection(w http.ResponseWriter, r *http.Request) {
+	doc, err := fs.Collection("sharedCollections").Doc(chi.URLParam(r, "token")).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var share sharedCollectionDoc
+	if err := doc.DataTo(&share); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	cdoc, err := collectionsCol(share.OwnerID).Doc(share.CollectionID).Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	col, err := collectionFromDoc(cdoc)
+	if err != nil || col.ShareToken != doc.Ref.ID { // revoked while the lookup doc lingered
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	page, ok := collectionPage(w, r, share.OwnerID, col.ID, "")
+	if !ok { return }
+	resp := sharedCollectionResponse{Collection: collectionResponses(r.Context(), "", []Collection{col})[0], Posts: page}
+	if authors, err := models.LoadAuthors(r.Context(), fs, []string{share.OwnerID}); err == nil {
+		resp.Owner = authors[share.OwnerID]
+	}
+	if uid, err := auth.VerifyFirebaseToken(r.Context(), r); err == nil {
+		models.Personalize(r.Context(), fs, uid, resp.Posts.Items)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
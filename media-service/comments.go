@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/counters"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── comments ─────────────────────────────────────────────────────────── */
+
+const maxCommentRunes = 2200
+
+type commentRequest struct {
+	Text     string `json:"text"`
+	ParentID string `json:"parentID"` // reply to this top-level comment
+}
+
+func createComment(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if msg := validateCommentText(req.Text); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	ref := postRef.Collection("comments").NewDoc()
+	comment := models.Comment{ID: ref.ID, PostID: postRef.ID, AuthorID: uid, Text: strings.TrimSpace(req.Text), ParentID: req.ParentID}
+	comment.Entities = models.TextEntities(r.Context(), fs, uid, comment.Text)
+	comment.Moderation = models.Moderation{}.With(sourceText, moderation.Text(r.Context(), clf, comment.Text))
+
+	var post models.Post
+	shards := 0
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(postRef)
+		if err != nil { return err }
+		if post, err = models.PostFromDoc(doc); err != nil { return err }
+		shards = counters.Shards(doc)
+		if err := checkVisible(c, uid, post); err != nil { return err }
+
+		if comment.ParentID != "" {
+			parentRef := models.CommentRef(fs, post.ID, comment.ParentID)
+			pdoc, err := tx.Get(parentRef)
+			if err != nil { return err }
+			parent, err := models.CommentFromDoc(pdoc)
+			if err != nil { return err }
+			if parent.ParentID != "" { return status.Error(codes.InvalidArgument, "replies cannot be replied to") }
+			if err := tx.Update(parentRef, []firestore.Update{{Path: "replyCount", Value: firestore.Increment(1)}}); err != nil { return err }
+		}
+		if err := tx.Create(ref, map[string]any{
+			"id":         comment.ID,
+			"postID":     comment.PostID,
+			"authorID":   comment.AuthorID,
+			"text":       comment.Text,
+			"entities":   comment.Entities,
+			"moderation": comment.Moderation,
+			"parentID":   comment.ParentID,
+			"likeCount":  0,
+			"replyCount": 0,
+			"timestamp":  firestore.ServerTimestamp,
+		}); err != nil { return err }
+		return counters.Add(tx, postRef, shards, "commentCount", 1)
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	counters.Seen(r.Context(), fs, postRef, shards)
+
+	item := moderation.QueueItem{PostID: post.ID, CommentID: comment.ID, AuthorID: uid}
+	if err := moderation.Enqueue(r.Context(), fs, item, "", comment.Moderation, sourceText); err != nil {
+		log.Printf("comment %s/%s: queue for moderation: %v", post.ID, comment.ID, err)
+	}
+	if !comment.Moderation.Blocked() { // nobody else will ever see it
+		authors, _ := models.LoadAuthors(r.Context(), fs, []string{uid})
+		events.Publish(r.Context(), postTopic, "POST_COMMENTED", map[string]string{
+			"postID": post.ID, "authorID": post.AuthorID, "commentID": comment.ID, "parentID": comment.ParentID,
+			"commentedBy": uid, "commentedByName": authors[uid].Username,
+		})
+		announceMentions(r.Context(), post, comment.ID, uid, comment.Entities, nil)
+	}
+
+	writeComment(w, r, uid, ref, http.StatusCreated)
+}
+
+// listComments pages through top-level comments. ?sort=top orders by likes,
+// anything else newest first.
+func listComments(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	if _, ok := loadVisiblePost(w, r, uid); !ok { return }
+	col := fs.Collection("posts").Doc(chi.URLParam(r, "id")).Collection("comments")
+	q := col.Where("parentID", "==", "")
+	if r.URL.Query().Get("sort") == "top" {
+		q = q.OrderBy("likeCount", firestore.Desc)
+	}
+	q = q.OrderBy("timestamp", firestore.Desc)
+	writeCommentPage(w, r, uid, col, q)
+}
+
+// listReplies pages through the replies of one comment, oldest first.
+func listReplies(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	if _, ok := loadVisiblePost(w, r, uid); !ok { return }
+	col := fs.Collection("posts").Doc(chi.URLParam(r, "id")).Collection("comments")
+	q := col.Where("parentID", "==", chi.URLParam(r, "cid")).OrderBy("timestamp", firestore.Asc)
+	writeCommentPage(w, r, uid, col, q)
+}
+
+func editComment(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	var req commentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if msg := validateCommentText(req.Text); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	ref := models.CommentRef(fs, postRef.ID, chi.URLParam(r, "cid"))
+	text := strings.TrimSpace(req.Text)
+	entities := models.TextEntities(r.Context(), fs, uid, text)
+	res := moderation.Text(r.Context(), clf, text)
+	var before []models.Entity
+	var mod models.Moderation
+	var post models.Post
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		var err error
+		if post, err = visiblePostTx(c, tx, uid, postRef); err != nil { return err }
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		comment, err := models.CommentFromDoc(doc)
+		if err != nil { return err }
+		if comment.AuthorID != uid { return status.Error(codes.PermissionDenied, "not the author") }
+		before, mod = comment.Entities, comment.Moderation.With(sourceText, res)
+		item := moderation.QueueItem{PostID: comment.PostID, CommentID: comment.ID, AuthorID: uid}
+		if err := moderation.EnqueueTx(tx, fs, item, comment.Moderation.Decision, mod, sourceText); err != nil { return err }
+		return tx.Update(ref, []firestore.Update{
+			{Path: "text", Value: text},
+			{Path: "entities", Value: entities},
+			{Path: "moderation", Value: mod},
+			{Path: "edited", Value: true},
+			{Path: "editedAt", Value: firestore.ServerTimestamp},
+		})
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	if !mod.Blocked() { announceMentions(r.Context(), post, ref.ID, uid, entities, before) }
+	writeComment(w, r, uid, ref, http.StatusOK)
+}
+
+// deleteComment may be called by the comment's author or the post's owner.
+// Deleting a top-level comment removes its replies too.
+func deleteComment(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	ref := models.CommentRef(fs, postRef.ID, chi.URLParam(r, "cid"))
+
+	doc, err := ref.Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	comment, err := models.CommentFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	if comment.AuthorID != uid {
+		pdoc, err := postRef.Get(r.Context())
+		if err != nil {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if owner, _ := pdoc.Data()["authorID"].(string); owner != uid {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+	}
+
+	// replies first, in transactions that keep commentCount in step
+	if comment.ParentID == "" {
+		for {
+			replies, err := postRef.Collection("comments").Where("parentID", "==", comment.ID).
+				Limit(200).Documents(r.Context()).GetAll()
+			if err != nil {
+				http.Error(w, "db read err", http.StatusInternalServerError)
+				return
+			}
+			if len(replies) == 0 { break }
+			for _, d := range replies {
+				if err := fsutil.DeleteSubcollections(r.Context(), fs, d.Ref); err != nil {
+					http.Error(w, "db write err", http.StatusInternalServerError)
+					return
+				}
+			}
+			if err := deleteComments(r.Context(), postRef, replies, ""); err != nil {
+				http.Error(w, "db write err", http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := fsutil.DeleteSubcollections(r.Context(), fs, ref); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	if err := deleteComments(r.Context(), postRef, []*firestore.DocumentSnapshot{doc}, comment.ParentID); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deleteComments deletes the comments of docs that still exist and takes
+// exactly those off the post's commentCount and, for replies, off
+// parentID's replyCount. Reading and writing in one transaction means a
+// comment deleted twice concurrently is only counted once.
+func deleteComments(c context.Context, postRef *firestore.DocumentRef, docs []*firestore.DocumentSnapshot, parentID string) error {
+	refs := make([]*firestore.DocumentRef, len(docs))
+	for i, d := range docs { refs[i] = d.Ref }
+	return fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		pdoc, err := tx.Get(postRef)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+		var parent *firestore.DocumentSnapshot
+		if parentID != "" {
+			parent, err = tx.Get(models.CommentRef(fs, postRef.ID, parentID))
+			if err != nil && status.Code(err) != codes.NotFound { return err }
+		}
+		current, err := tx.GetAll(refs)
+		if err != nil { return err }
+
+		var n int64
+		for _, d := range current {
+			if !d.Exists() { continue } // deleted in the meantime
+			if err := tx.Delete(d.Ref); err != nil { return err }
+			n++
+		}
+		if n == 0 || !pdoc.Exists() { return nil }
+		if err := counters.Add(tx, postRef, counters.Shards(pdoc), "commentCount", -n); err != nil { return err }
+		if parent == nil || !parent.Exists() { return nil }
+		return tx.Update(parent.Ref, []firestore.Update{{Path: "replyCount", Value: firestore.Increment(-n)}})
+	})
+}
+
+func likeComment(w http.ResponseWriter, r *http.Request)   { setCommentLike(w, r, true) }
+func unlikeComment(w http.ResponseWriter, r *http.Request) { setCommentLike(w, r, false) }
+
+func setCommentLike(w http.ResponseWriter, r *http.Request, like bool) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	postID, commentID := chi.URLParam(r, "id"), chi.URLParam(r, "cid")
+	_, count, err := toggleLike(r.Context(), models.CommentRef(fs, postID, commentID),
+		models.CommentLikeRef(fs, postID, commentID, uid), uid, like,
+		func(tx *firestore.Transaction, doc *firestore.DocumentSnapshot) error {
+			if _, err := visiblePostTx(r.Context(), tx, uid, fs.Collection("posts").Doc(postID)); err != nil { return err }
+			comment, err := models.CommentFromDoc(doc)
+			if err != nil { return err }
+			if comment.Moderation.Blocked() && comment.AuthorID != uid { return status.Error(codes.NotFound, "comment blocked") }
+			return nil
+		})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(likeResponse{Liked: like, LikeCount: count})
+}
+
+/* ────── comment helpers ──────────────────────────────────────────────────── */
+
+func validateCommentText(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" { return "empty comment" }
+	if utf8.RuneCountInString(text) > maxCommentRunes { return "comment too long" }
+	return ""
+}
+
+func writeComment(w http.ResponseWriter, r *http.Request, uid string, ref *firestore.DocumentRef, code int) {
+	doc, err := ref.Get(r.Context())
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	comment, err := models.CommentFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(models.CommentResponses(r.Context(), fs, uid, []models.Comment{comment})[0])
+}
+
+func writeCommentPage(w http.ResponseWriter, r *http.Request, uid string, col *firestore.CollectionRef, q firestore.Query) {
+	limit := paging.Limit(r, 20, 100)
+	q, err := paging.StartAfter(r, col, q.Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	comments := make([]models.Comment, 0, len(docs))
+	for _, d := range docs {
+		c, err := models.CommentFromDoc(d)
+		if err != nil { continue }
+		if c.Moderation.Blocked() && c.AuthorID != uid { continue } // blocked by moderation
+		comments = append(comments, c)
+	}
+	page := paging.Page[models.CommentResponse]{Items: models.CommentResponses(r.Context(), fs, uid, comments)}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
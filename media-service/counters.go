@@ -0,0 +1,25 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/counters"
+)
+
+/* ────── counter roll-up ──────────────────────────────────────────────────── */
+
+// rollupCounters folds the counter shards written since the last run (hot
+// posts here, popular users from user-service) back into their documents; see
+// the counters package. Triggered by Cloud Scheduler every minute.
+func rollupCounters(w http.ResponseWriter, r *http.Request) {
+	n, err := counters.RollUp(r.Context(), fs)
+	if err != nil {
+		log.Printf("rollup counters: %v", err)
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"rolledUp": n})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── drafts & scheduling ──────────────────────────────────────────────── */
+
+// Posts can be scheduled at most this far ahead.
+const maxScheduleAhead = 365 * 24 * time.Hour
+
+// unpublished are the statuses listed on the drafts screen.
+var unpublished = []string{models.StatusPendingUpload, models.StatusDraft, models.StatusScheduled}
+
+// parsePublishAt validates a requested publish time; "" means none.
+func parsePublishAt(s string) (time.Time, string) {
+	if s == "" { return time.Time{}, "" }
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil { return t, "publishAt must be RFC 3339" }
+	now := time.Now()
+	if !t.After(now) { return t, "publishAt must be in the future" }
+	if t.After(now.Add(maxScheduleAhead)) { return t, "publishAt too far ahead" }
+	return t.UTC(), ""
+}
+
+// listDrafts pages through the caller's unpublished posts, newest first.
+func listDrafts(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	col := fs.Collection("posts")
+	limit := paging.Limit(r, 20, 50)
+	q, err := paging.StartAfter(r, col, col.Where("authorID", "==", uid).Where("status", "in", unpublished).
+		OrderBy("timestamp", firestore.Desc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	posts := make([]models.Post, 0, len(docs))
+	for _, d := range docs {
+		if p, err := models.PostFromDoc(d); err == nil { posts = append(posts, p) }
+	}
+	page := paging.Page[models.PostResponse]{Items: models.PostResponses(r.Context(), fs, posts)}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// scheduleUpdates turns an edit of publishAt into post updates: a time
+// schedules the post, "" takes it back to a draft. Only unpublished posts
+// can be (re)scheduled.
+func scheduleUpdates(post models.Post, publishAt string) ([]firestore.Update, string) {
+	switch post.Status {
+	case models.StatusPendingUpload, models.StatusDraft, models.StatusScheduled:
+	default:
+		return nil, "post already " + post.Status
+	}
+	t, msg := parsePublishAt(publishAt)
+	if msg != "" { return nil, msg }
+
+	var ups []firestore.Update
+	if t.IsZero() {
+		ups = append(ups, firestore.Update{Path: "publishAt", Value: firestore.Delete},
+			firestore.Update{Path: "draft", Value: true})
+	} else {
+		ups = append(ups, firestore.Update{Path: "publishAt", Value: t},
+			firestore.Update{Path: "draft", Value: false})
+	}
+	if post.Status != models.StatusPendingUpload { // still uploading: finalize decides
+		next := models.StatusDraft
+		if !t.IsZero() { next = models.StatusScheduled }
+		ups = append(ups, firestore.Update{Path: "status", Value: next})
+	}
+	return ups, ""
+}
+
+// publishNow publishes one of the caller's drafts or scheduled posts
+// immediately.
+func publishNow(w http.ResponseWriter, r *http.Request) {
+	post, ref, ok := loadOwnPost(w, r)
+	if !ok { return }
+
+	published, err := publishPost(r.Context(), ref, time.Now().UTC())
+	if status.Code(err) == codes.FailedPrecondition {
+		http.Error(w, "post already "+post.Status, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	announcePublished(r.Context(), published)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// publishScheduled publishes every scheduled post that is due. Triggered by
+// Cloud Scheduler every minute.
+func publishScheduled(w http.ResponseWriter, r *http.Request) {
+	docs, err := fs.Collection("posts").
+		Where("status", "==", models.StatusScheduled).
+		Where("publishAt", "<=", time.Now()).
+		OrderBy("publishAt", firestore.Asc).
+		Limit(500).Documents(r.Context()).GetAll()
+	if err != nil {
+		log.Printf("publish scheduled: %v", err)
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	n := 0
+	for _, d := range docs {
+		post, err := models.PostFromDoc(d)
+		if err != nil { continue }
+		// the timestamp is the intended publish time, not when this run got to it
+		published, err := publishPost(r.Context(), d.Ref, post.PublishAt)
+		if status.Code(err) == codes.FailedPrecondition { continue } // unscheduled or published meanwhile
+		if err != nil {
+			log.Printf("publish scheduled %s: %v", post.ID, err)
+			continue
+		}
+		announcePublished(r.Context(), published)
+		n++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"published": n})
+}
+
+// publishPost moves a draft or scheduled post live with its timestamp set
+// to at, so it sorts into feeds as if it had been posted then. It fails
+// with FailedPrecondition when the post is in any other state.
+func publishPost(c context.Context, ref *firestore.DocumentRef, at time.Time) (models.Post, error) {
+	var post models.Post
+	err := fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if post, err = models.PostFromDoc(doc); err != nil { return err }
+		if post.Status != models.StatusDraft && post.Status != models.StatusScheduled {
+			return status.Error(codes.FailedPrecondition, "not a draft")
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: models.StatusPublished},
+			{Path: "timestamp", Value: at},
+			{Path: "publishedAt", Value: firestore.ServerTimestamp},
+			{Path: "draft", Value: firestore.Delete},
+			{Path: "publishAt", Value: firestore.Delete},
+		})
+	})
+	return post, err
+}
+
+// announcePublished tells other services a post went live, along with
+// whoever its caption mentions. post may still carry its old status.
+func announcePublished(c context.Context, post models.Post) {
+	events.Publish(c, postTopic, "POST_PUBLISHED", map[string]string{
+		"postID": post.ID, "authorID": post.AuthorID, "object": post.MediaPath,
+	})
+	post.Status = models.StatusPublished
+	announceMentions(c, post, "", post.AuthorID, post.Entities, nil)
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+/* ────── upload finalization ──────────────────────────────────────────────── */
+
+// Upper bounds for a single uploaded object, per media type.
+var maxUploadBytes = map[string]int64{
+	"image": 25 << 20,  // 25 MiB
+	"video": 500 << 20, // 500 MiB
+}
+
+// Posts still pending upload after draftTTL are garbage-collected.
+const draftTTL = 24 * time.Hour
+
+var errMediaInvalid = errors.New("media invalid")
+
+// finalizePost is called by the client once its uploads (signed PUT or
+// resumable session) have completed. It verifies the objects and publishes
+// the post.
+func finalizePost(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	doc, err := postRef.Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	post, err := models.PostFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	if post.AuthorID != uid {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if post.Status != models.StatusPendingUpload {
+		http.Error(w, "post already "+post.Status, http.StatusConflict)
+		return
+	}
+
+	for i, m := range post.Items() {
+		err := verifyUpload(r.Context(), m)
+		if err == nil { continue }
+		if errors.Is(err, blobstore.ErrNotExist) {
+			http.Error(w, fmt.Sprintf("upload %d not found", i), http.StatusConflict) // client may retry
+			return
+		}
+		if !errors.Is(err, errMediaInvalid) {
+			log.Printf("finalize %s: %v", post.ID, err)
+			http.Error(w, "storage err", http.StatusInternalServerError)
+			return
+		}
+		if rejectUpload(r.Context(), postRef) { _ = deleteMedia(r.Context(), post) }
+		http.Error(w, fmt.Sprintf("media %d: %v", i, err), http.StatusUnprocessableEntity)
+		return
+	}
+
+	post, next, err := finalizeStatus(r.Context(), postRef)
+	if status.Code(err) == codes.FailedPrecondition {
+		http.Error(w, "post already "+post.Status, http.StatusConflict) // a concurrent call won
+		return
+	}
+	if err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	if err := dropUploadSessions(r.Context(), post.ID); err != nil {
+		log.Printf("finalize %s: drop upload sessions: %v", post.ID, err)
+	}
+
+	if next == models.StatusPublished { announcePublished(r.Context(), post) }
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// finalizeStatus moves a post that is still pending upload on: drafts and
+// scheduled posts wait for publishPost, the rest go live. Check and write
+// share a transaction so that of two concurrent finalize calls exactly one
+// moves the post; the other fails with FailedPrecondition. It returns the
+// post as read and its new status.
+func finalizeStatus(c context.Context, ref *firestore.DocumentRef) (models.Post, string, error) {
+	var post models.Post
+	var next string
+	err := fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if post, err = models.PostFromDoc(doc); err != nil { return err }
+		if post.Status != models.StatusPendingUpload { return status.Error(codes.FailedPrecondition, "not pending upload") }
+
+		next = models.StatusPublished
+		switch {
+		case post.Draft:
+			next = models.StatusDraft
+		case post.PublishAt.After(time.Now()):
+			next = models.StatusScheduled
+		}
+		updates := []firestore.Update{{Path: "status", Value: next}}
+		if next == models.StatusPublished {
+			updates = append(updates, firestore.Update{Path: "publishedAt", Value: firestore.ServerTimestamp})
+		}
+		return tx.Update(ref, updates)
+	})
+	return post, next, err
+}
+
+// rejectUpload marks a post whose media failed verification as rejected,
+// unless a concurrent finalize already moved it on, and reports whether it
+// did.
+func rejectUpload(c context.Context, ref *firestore.DocumentRef) bool {
+	err := fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if st, _ := doc.DataAt("status"); st != models.StatusPendingUpload { return status.Error(codes.FailedPrecondition, "not pending upload") }
+		return tx.Update(ref, []firestore.Update{{Path: "status", Value: models.StatusRejected}})
+	})
+	return err == nil
+}
+
+// uploadLimit is the most an item's upload may weigh: its declared size,
+// which the quota was charged for, or the media type's limit for items
+// created before sizes were required.
+func uploadLimit(m models.MediaItem) int64 {
+	if m.Size > 0 { return m.Size }
+	return maxUploadBytes[m.Type]
+}
+
+// verifyUpload checks the object exists, is within the item's upload limit
+// and that its sniffed content type matches the item's media type.
+func verifyUpload(c context.Context, m models.MediaItem) error {
+	attrs, err := store.Attrs(c, m.Path)
+	if err != nil { return err }
+
+	if _, ok := maxUploadBytes[m.Type]; !ok { return fmt.Errorf("%w: unknown media type %q", errMediaInvalid, m.Type) }
+	if limit := uploadLimit(m); attrs.Size == 0 || attrs.Size > limit {
+		return fmt.Errorf("%w: size %d outside 1..%d bytes", errMediaInvalid, attrs.Size, limit)
+	}
+
+	rc, err := store.NewRangeReader(c, m.Path, 0, 512)
+	if err != nil { return err }
+	defer rc.Close()
+	head, err := io.ReadAll(rc)
+	if err != nil { return err }
+
+	sniffed := http.DetectContentType(head)
+	if !strings.HasPrefix(sniffed, m.Type+"/") {
+		return fmt.Errorf("%w: content is %s, expected %s", errMediaInvalid, sniffed, m.Type)
+	}
+	return nil
+}
+
+// deleteMedia removes every uploaded object of post; missing objects are fine.
+func deleteMedia(c context.Context, post models.Post) error {
+	for _, m := range post.Items() {
+		err := store.Delete(c, m.Path)
+		if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+			return fmt.Errorf("delete %s: %w", m.Path, err)
+		}
+	}
+	return nil
+}
+
+// purgePost deletes a post that never went out together with its media and
+// subcollections (upload sessions and the like).
+func purgePost(c context.Context, post models.Post) error {
+	if err := deleteMedia(c, post); err != nil { return err }
+	ref := fs.Collection("posts").Doc(post.ID)
+	if err := fsutil.DeleteSubcollections(c, fs, ref); err != nil { return err }
+	if _, err := ref.Delete(c); err != nil { return fmt.Errorf("delete post %s: %w", post.ID, err) }
+	return nil
+}
+
+// gcDrafts deletes posts (and any partial upload) that were never finalized
+// or whose upload was rejected. Triggered by Cloud Scheduler.
+func gcDrafts(w http.ResponseWriter, r *http.Request) {
+	cutoff := time.Now().Add(-draftTTL)
+	iter := fs.Collection("posts").
+		Where("status", "in", []string{models.StatusPendingUpload, models.StatusRejected}).
+		Where("timestamp", "<", cutoff).
+		Limit(500).Documents(r.Context())
+	defer iter.Stop()
+
+	n := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done { break }
+		if err != nil {
+			log.Printf("gc drafts: %v", err)
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		post, err := models.PostFromDoc(doc)
+		if err != nil { continue }
+		if err := purgePost(r.Context(), post); err != nil {
+			log.Printf("gc drafts: %v", err)
+			continue
+		}
+		n++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]int{"deleted": n})
+}
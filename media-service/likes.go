@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/counters"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── likes ────────────────────────────────────────────────────────────── */
+
+type likeResponse struct {
+	Liked     bool  `json:"liked"`
+	LikeCount int64 `json:"likeCount"`
+}
+
+func likePost(w http.ResponseWriter, r *http.Request)   { setLike(w, r, true) }
+func unlikePost(w http.ResponseWriter, r *http.Request) { setLike(w, r, false) }
+
+// setLike creates or removes the caller's like on a post.
+func setLike(w http.ResponseWriter, r *http.Request, like bool) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+
+	var post models.Post
+	changed, count, err := toggleLike(r.Context(), postRef, models.LikeRef(fs, postRef.ID, uid), uid, like,
+		func(_ *firestore.Transaction, doc *firestore.DocumentSnapshot) (err error) {
+			if post, err = models.PostFromDoc(doc); err != nil { return err }
+			return checkVisible(r.Context(), uid, post)
+		})
+	if status.Code(err) == codes.NotFound {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+
+	if changed && like {
+		authors, _ := models.LoadAuthors(r.Context(), fs, []string{uid})
+		events.Publish(r.Context(), postTopic, "POST_LIKED", map[string]string{
+			"postID": post.ID, "authorID": post.AuthorID,
+			"likedBy": uid, "likedByName": authors[uid].Username,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(likeResponse{Liked: like, LikeCount: count})
+}
+
+// toggleLike puts likeRef into the requested state and keeps likeCount on
+// parent in step within one transaction, so repeating a request never
+// double counts. check vets the parent document, and may read more within
+// tx, before anything is written.
+// On sharded parents the returned count lags by up to one counter roll-up.
+func toggleLike(c context.Context, parent, likeRef *firestore.DocumentRef, uid string, like bool,
+	check func(*firestore.Transaction, *firestore.DocumentSnapshot) error) (changed bool, count int64, err error) {
+
+	shards := 0
+	err = fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		changed = false
+		doc, err := tx.Get(parent)
+		if err != nil { return err }
+		if err := check(tx, doc); err != nil { return err }
+		count, _ = doc.Data()["likeCount"].(int64)
+		shards = counters.Shards(doc)
+
+		existing, err := tx.Get(likeRef)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+		if exists := err == nil && existing.Exists(); exists == like { return nil } // already in the requested state
+
+		changed = true
+		delta := int64(1)
+		if like {
+			err = tx.Create(likeRef, map[string]any{"uid": uid, "timestamp": firestore.ServerTimestamp})
+		} else {
+			delta = -1
+			err = tx.Delete(likeRef)
+		}
+		if err != nil { return err }
+		count += delta
+		return counters.Add(tx, parent, shards, "likeCount", delta)
+	})
+	if err == nil && changed { counters.Seen(c, fs, parent, shards) }
+	return changed, count, err
+}
+
+type likerResponse struct {
+	models.AuthorSummary
+	LikedAt string `json:"likedAt"`
+}
+
+type likeDoc struct {
+	UID       string    `firestore:"uid"`
+	Timestamp time.Time `firestore:"timestamp"`
+}
+
+// listLikers pages through who liked a post, newest first.
+func listLikers(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	if _, ok := loadVisiblePost(w, r, uid); !ok { return }
+	likes := fs.Collection("posts").Doc(chi.URLParam(r, "id")).Collection("likes")
+	limit := paging.Limit(r, 20, 100)
+
+	q, err := paging.StartAfter(r, likes, likes.OrderBy("timestamp", firestore.Desc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	rows := make([]likeDoc, 0, len(docs))
+	uids := make([]string, 0, len(docs))
+	for _, d := range docs {
+		var l likeDoc
+		if err := d.DataTo(&l); err != nil { continue }
+		l.UID = d.Ref.ID
+		rows = append(rows, l)
+		uids = append(uids, l.UID)
+	}
+	authors, _ := models.LoadAuthors(r.Context(), fs, uids)
+
+	page := paging.Page[likerResponse]{Items: make([]likerResponse, 0, len(rows))}
+	for _, l := range rows {
+		page.Items = append(page.Items, likerResponse{AuthorSummary: authors[l.UID], LikedAt: models.FormatTime(l.Timestamp)})
+	}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
@@ -4,32 +4,37 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
-	"time"
+	"strings"
 
 	"cloud.google.com/go/firestore"
 	"cloud.google.com/go/pubsub"
-	"cloud.google.com/go/storage"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
 	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
 )
 
 /* ────── env vars ─────────────────────────────────────────────────────────── */
 
 var (
-	projectID = mustEnv("GOOGLE_CLOUD_PROJECT")
+	projectID string // GOOGLE_CLOUD_PROJECT, read in main so that tests can load the package
 	port      = "8080"
 
-	bucket    = mustEnv("MEDIA_BUCKET")      // "<project>.appspot.com"
-	postTopic = mustEnv("POST_EVENTS_TOPIC") // "post-events"
-	signerSA  = mustEnv("SERVICE_ACCOUNT_EMAIL")
-	signerKey = mustEnv("SERVICE_ACCOUNT_KEY_PATH")
+	postTopic string // POST_EVENTS_TOPIC, e.g. "post-events"
+	// the media bucket is configured by STORAGE_BACKEND and friends, see
+	// blobstore.FromEnv; uploads need a store that can sign
 )
 
 /* ────── globals (initialised in main) ────────────────────────────────────── */
@@ -37,46 +42,118 @@ var (
 var (
 	ctx     context.Context
 	fs      *firestore.Client
-	stor    *storage.Client
+	store   blobstore.Store
 	pubClnt *pubsub.Client
+	clf     moderation.Classifier
 )
 
 /* ────── main ─────────────────────────────────────────────────────────────── */
 
 func main() {
 	ctx = context.Background()
+	projectID, postTopic = mustEnv("GOOGLE_CLOUD_PROJECT"), mustEnv("POST_EVENTS_TOPIC")
 
 	var err error
 	if fs, err = firestore.NewClient(ctx, projectID); err != nil {
 		log.Fatalf("firestore: %v", err)
 	}
-	if stor, err = storage.NewClient(ctx); err != nil {
+	if store, err = blobstore.FromEnv(ctx); err != nil {
 		log.Fatalf("storage: %v", err)
 	}
+	if !store.CanSign() {
+		log.Fatalf("storage: no signing credentials")
+	}
 	if err = auth.Init(ctx); err != nil {
 		log.Fatalf("auth init: %v", err)
 	}
 	if err = events.Init(ctx, projectID); err != nil {
 		log.Fatalf("events init: %v", err)
 	}
+	signing.Init(store)
+	if clf, err = moderation.FromEnv(); err != nil {
+		log.Fatalf("moderation init: %v", err)
+	}
+	internal, err := auth.Invoker()
+	if err != nil {
+		log.Fatalf("internal routes: %v", err)
+	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
 	r.Post("/posts", createPost)
 	r.Get("/posts/{id}", getPost)
+	r.Patch("/posts/{id}", editPost)
+	r.Delete("/posts/{id}", deletePost)
+	r.Post("/posts/{id}/finalize", finalizePost)
+	r.Post("/posts/{id}/publish", publishNow)
+	r.Get("/posts/drafts", listDrafts)
+	r.Get("/posts/{id}/media/{idx}/upload", uploadStatus)
+	r.Post("/posts/{id}/media/{idx}/upload", restartUpload)
+	r.Post("/posts/{id}/like", likePost)
+	r.Delete("/posts/{id}/like", unlikePost)
+	r.Get("/posts/{id}/likes", listLikers)
+	r.Post("/posts/{id}/save", savePost)
+	r.Delete("/posts/{id}/save", unsavePost)
+	r.Get("/saved", listSaved)
+	r.Get("/collections", listCollections)
+	r.Post("/collections", createCollection)
+	r.Put("/collections/order", reorderCollections)
+	r.Patch("/collections/{cid}", editCollection)
+	r.Delete("/collections/{cid}", dropCollection)
+	r.Get("/collections/{cid}/posts", collectionPosts)
+	r.Put("/collections/{cid}/posts/{postID}", addToCollection)
+	r.Delete("/collections/{cid}/posts/{postID}", removeFromCollection)
+	r.Post("/collections/{cid}/share", shareCollection)
+	r.Delete("/collections/{cid}/share", unshareCollection)
+	r.Get("/shared/collections/{token}", sharedCollection)
+
+	r.Post("/posts/{id}/comments", createComment)
+	r.Get("/posts/{id}/comments", listComments)
+	r.Patch("/posts/{id}/comments/{cid}", editComment)
+	r.Delete("/posts/{id}/comments/{cid}", deleteComment)
+	r.Get("/posts/{id}/comments/{cid}/replies", listReplies)
+	r.Post("/posts/{id}/comments/{cid}/like", likeComment)
+	r.Delete("/posts/{id}/comments/{cid}/like", unlikeComment)
+
+	r.Put("/posts/{id}/products", setProductTags)
+	r.Get("/products/{id}/posts", productPosts)
+
+	// Cloud Scheduler
+	r.With(internal).Post("/internal/gc-drafts", gcDrafts)
+	r.With(internal).Post("/internal/publish-scheduled", publishScheduled)
+	r.With(internal).Post("/internal/rollup-counters", rollupCounters)
+
 	r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("media-svc OK")) })
 
+	// offline development: serve the signed URLs of the local store
+	if l, ok := store.(*blobstore.Local); ok { r.Handle(l.Prefix()+"*", l) }
+
 	log.Printf("media-service listening on :%s", port)
 	log.Fatal(http.ListenAndServe(":"+port, r))
 }
 
 /* ────── handlers ─────────────────────────────────────────────────────────── */
 
-type postRequest struct {
-	Caption   string `json:"caption"`
+type mediaRequest struct {
 	MediaType string `json:"mediaType"` // "image" | "video"
 	FileExt   string `json:"fileExt"`   // optional; jpg/mp4 guessed if empty
+	Size      int64  `json:"size"`      // bytes, required; large items get a resumable session
+	Resumable bool   `json:"resumable"` // force a resumable session
+}
+
+type postRequest struct {
+	Caption    string         `json:"caption"`
+	Tags       []string       `json:"tags"`
+	MediaType  string         `json:"mediaType"` // single-media posts
+	FileExt    string         `json:"fileExt"`
+	Size       int64          `json:"size"`
+	Media      []mediaRequest `json:"media"`      // carousel, in display order; wins over the single fields
+	Visibility string         `json:"visibility"` // public (default), followers, close_friends or private
+	Draft      bool           `json:"draft"`      // keep as a draft after finalize
+	PublishAt  string         `json:"publishAt"`  // RFC 3339; schedule instead of publishing on finalize
+	Kind       string         `json:"kind"`       // "" or "standard", or "before_after"
+	Steps      []models.Step  `json:"steps"`      // before_after only
 }
 
 func createPost(w http.ResponseWriter, r *http.Request) {
@@ -87,84 +164,240 @@ func createPost(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req postRequest
-	_ = json.NewDecoder(r.Body).Decode(&req)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
 
-	if req.FileExt == "" {
-		if req.MediaType == "video" {
-			req.FileExt = "mp4"
-		} else {
-			req.FileExt = "jpg"
+	if len(req.Media) == 0 {
+		req.Media = []mediaRequest{{MediaType: req.MediaType, FileExt: req.FileExt, Size: req.Size}}
+	}
+	if len(req.Media) > models.MaxMediaItems {
+		http.Error(w, fmt.Sprintf("at most %d media items", models.MaxMediaItems), http.StatusBadRequest)
+		return
+	}
+	if req.Visibility == "" { req.Visibility = models.VisibilityPublic }
+	if !models.Visibilities[req.Visibility] {
+		http.Error(w, "invalid visibility", http.StatusBadRequest)
+		return
+	}
+	publishAt, msg := parsePublishAt(req.PublishAt)
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	var steps []models.Step
+	switch req.Kind {
+	case "", models.KindStandard:
+		if len(req.Steps) > 0 {
+			http.Error(w, "steps only apply to before_after posts", http.StatusBadRequest)
+			return
+		}
+	case models.KindBeforeAfter:
+		if msg := validatePair(req.Media); msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		if steps, msg, err = cleanSteps(r.Context(), req.Steps); err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
 		}
+	default:
+		http.Error(w, "kind must be standard or before_after", http.StatusBadRequest)
+		return
 	}
 
-	// ── Firestore doc & Signed URL ────────────────────────────────────────
 	postRef := fs.Collection("posts").NewDoc()
-	objPath := fmt.Sprintf("posts/%s/%s.%s", authorUID, postRef.ID, req.FileExt)
-
-	uploadURL, err := signedUploadURL(objPath, 15*time.Minute)
-	if err != nil {
-		http.Error(w, "signed-url err", http.StatusInternalServerError)
-		return
+	items := make([]models.MediaItem, 0, len(req.Media))
+	var declared int64
+	for i, m := range req.Media {
+		exts, ok := allowedMedia[m.MediaType]
+		if !ok {
+			http.Error(w, fmt.Sprintf("media %d: mediaType must be image or video", i), http.StatusBadRequest)
+			return
+		}
+		m.FileExt = strings.ToLower(strings.TrimPrefix(m.FileExt, "."))
+		if m.FileExt == "" {
+			if m.MediaType == "video" {
+				m.FileExt = "mp4"
+			} else {
+				m.FileExt = "jpg"
+			}
+		}
+		if _, ok := exts[m.FileExt]; !ok {
+			http.Error(w, fmt.Sprintf("media %d: .%s not allowed for %s", i, m.FileExt, m.MediaType), http.StatusUnsupportedMediaType)
+			return
+		}
+		if m.Size <= 0 {
+			http.Error(w, fmt.Sprintf("media %d: size is required", i), http.StatusBadRequest)
+			return
+		}
+		if limit := maxUploadBytes[m.MediaType]; m.Size > limit {
+			http.Error(w, fmt.Sprintf("media %d: size must be within 1..%d bytes", i, limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		declared += m.Size
+		items = append(items, models.MediaItem{Path: models.MediaObjectPath(authorUID, postRef.ID, i, m.FileExt), Type: m.MediaType, Size: m.Size})
+	}
+	cover := items[0]
+	entities := models.TextEntities(r.Context(), fs, authorUID, req.Caption)
+	mod := models.Moderation{}.With(sourceCaption, moderation.Text(r.Context(), clf, req.Caption))
+	modSource := sourceCaption
+	if len(steps) > 0 {
+		check := moderation.Text(r.Context(), clf, stepsText(steps))
+		if models.MoreSevere(check.Decision, mod.Decision) { modSource = sourceSteps }
+		mod = mod.With(sourceSteps, check)
 	}
 
 	// initial placeholder document
-	if _, err = postRef.Set(r.Context(), map[string]any{
+	doc := map[string]any{
 		"id":           postRef.ID,
 		"authorID":     authorUID,
 		"caption":      req.Caption,
-		"mediaPath":    objPath,
-		"mediaType":    req.MediaType,
+		"entities":     entities,
+		"tags":         models.NormalizeTags(append(req.Tags, models.Hashtags(entities)...)),
+		"mediaPath":    cover.Path,
+		"mediaType":    cover.Type,
+		"media":        items,
 		"likeCount":    0,
 		"commentCount": 0,
 		"timestamp":    firestore.ServerTimestamp,
 		"processed":    false,
-	}); err != nil {
-		http.Error(w, "db write err", http.StatusInternalServerError)
+		"status":       models.StatusPendingUpload,
+		"visibility":   req.Visibility,
+		"moderation":   mod,
+	}
+	if req.Draft { doc["draft"] = true }
+	if !publishAt.IsZero() { doc["publishAt"] = publishAt }
+	if req.Kind == models.KindBeforeAfter {
+		ba := &models.BeforeAfter{Steps: steps}
+		doc["kind"], doc["beforeAfter"] = models.KindBeforeAfter, ba
+		doc["productIDs"] = models.TaggedProductIDs(nil, ba)
+	}
+
+	// The post and its upload targets are created under a quota reservation
+	// that is handed back if either fails.
+	uploads := make([]uploadTarget, 0, len(items))
+	uploadURLs := make([]string, 0, len(items))
+	failure := "db write err"
+	err = withUploadQuota(r.Context(), quotas, authorUID, len(items), declared, func() error {
+		if _, err := postRef.Set(r.Context(), doc); err != nil { return err }
+
+		// ── Signed URLs / resumable sessions ──────────────────────────────
+		// opened once the post exists, so everything they leave behind hangs
+		// off a document that purgePost (or gcDrafts) can find
+		for i, m := range req.Media {
+			resumable := m.Resumable || m.Size > resumableThreshold
+			t, err := newUploadTarget(r.Context(), r, postRef.ID, i, items[i], resumable)
+			if err != nil {
+				log.Printf("create post %s: upload %d: %v", postRef.ID, i, err)
+				if err := purgePost(r.Context(), models.Post{ID: postRef.ID, Media: items}); err != nil {
+					log.Printf("create post %s: %v", postRef.ID, err) // left to gcDrafts
+				}
+				failure = "signed-url err"
+				return err
+			}
+			uploads = append(uploads, t)
+			uploadURLs = append(uploadURLs, t.UploadURL)
+		}
+		return nil
+	})
+	var qe *quotaError
+	if errors.As(err, &qe) {
+		writeQuotaErr(w, qe)
 		return
+	} else if err != nil {
+		http.Error(w, failure, http.StatusInternalServerError)
+		return
+	}
+	if err := moderation.Enqueue(r.Context(), fs, moderation.QueueItem{PostID: postRef.ID, AuthorID: authorUID}, "", mod, modSource); err != nil {
+		log.Printf("create post %s: queue for moderation: %v", postRef.ID, err)
 	}
 
 	events.Publish(r.Context(), postTopic, "POST_DRAFTED", map[string]string{
-		"postID": postRef.ID, "authorID": authorUID, "object": objPath,
+		"postID": postRef.ID, "authorID": authorUID, "object": cover.Path,
 	})
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(map[string]string{
-		"postID":    postRef.ID,
-		"uploadURL": uploadURL,
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"postID":     postRef.ID,
+		"uploadURL":  uploadURLs[0],
+		"uploadURLs": uploadURLs, // one per media item, same order as the request; "" for resumable items
+		"uploads":    uploads,
 	})
 }
 
 func getPost(w http.ResponseWriter, r *http.Request) {
-	if _, err := auth.VerifyFirebaseToken(r.Context(), r); err != nil {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
 		http.Error(w, "unauth", http.StatusUnauthorized)
 		return
 	}
 
-	docID := chi.URLParam(r, "id")
-	doc, err := fs.Collection("posts").Doc(docID).Get(r.Context())
+	post, ok := loadVisiblePost(w, r, uid)
+	if !ok { return }
+	resp := models.PostResponses(r.Context(), fs, []models.Post{post})
+	models.Personalize(r.Context(), fs, uid, resp)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp[0])
+}
+
+// loadVisiblePost fetches {id} if uid may see it. Unpublished posts are
+// only visible to their author; anything uid may not see is a 404 so its
+// existence is not given away.
+func loadVisiblePost(w http.ResponseWriter, r *http.Request, uid string) (models.Post, bool) {
+	doc, err := fs.Collection("posts").Doc(chi.URLParam(r, "id")).Get(r.Context())
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
-		return
+		return models.Post{}, false
 	}
-	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(doc.Data())
+	post, err := models.PostFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return models.Post{}, false
+	}
+	if !post.IsLive() && post.AuthorID != uid {
+		http.Error(w, "not found", http.StatusNotFound)
+		return models.Post{}, false
+	}
+	visible, err := models.CanView(r.Context(), fs, uid, post)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return models.Post{}, false
+	}
+	if !visible {
+		http.Error(w, "not found", http.StatusNotFound)
+		return models.Post{}, false
+	}
+	return post, true
 }
 
-/* ────── helpers ─────────────────────────────────────────────────────────── */
+// checkVisible is the transactional counterpart of loadVisiblePost for
+// writes that need the post to be live and visible to uid.
+func checkVisible(c context.Context, uid string, post models.Post) error {
+	if !post.IsLive() { return status.Error(codes.NotFound, "post not live") }
+	visible, err := models.CanView(c, fs, uid, post)
+	if err != nil { return err }
+	if !visible { return status.Error(codes.NotFound, "post not visible") }
+	return nil
+}
 
-func signedUploadURL(object string, ttl time.Duration) (string, error) {
-	keyBytes, err := os.ReadFile(signerKey) // ↓ ioutil deprecated
-	if err != nil {
-		return "", err
-	}
-	return storage.SignedURL(bucket, object, &storage.SignedURLOptions{
-		Method:         "PUT",
-		GoogleAccessID: signerSA,
-		PrivateKey:     keyBytes,
-		Expires:        time.Now().Add(ttl),
-	})
+// visiblePostTx reads the post at ref within tx and applies checkVisible.
+func visiblePostTx(c context.Context, tx *firestore.Transaction, uid string, ref *firestore.DocumentRef) (models.Post, error) {
+	doc, err := tx.Get(ref)
+	if err != nil { return models.Post{}, err }
+	post, err := models.PostFromDoc(doc)
+	if err != nil { return post, err }
+	return post, checkVisible(c, uid, post)
 }
 
+/* ────── helpers ─────────────────────────────────────────────────────────── */
+
 func mustEnv(k string) string {
 	v := os.Getenv(k)
 	if v == "" {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+/* ────── edit / delete ────────────────────────────────────────────────────── */
+
+// editRequest uses pointers so omitted fields stay untouched.
+type editRequest struct {
+	Caption    *string        `json:"caption"`
+	Tags       *[]string      `json:"tags"`
+	Visibility *string        `json:"visibility"`
+	PublishAt  *string        `json:"publishAt"` // unpublished posts only; "" unschedules
+	Steps      *[]models.Step `json:"steps"`     // before_after posts only; replaces all steps
+}
+
+func editPost(w http.ResponseWriter, r *http.Request) {
+	post, ref, ok := loadOwnPost(w, r)
+	if !ok { return }
+
+	var req editRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+
+	var updates []firestore.Update
+	tags, entities := post.Tags, post.Entities
+	if req.Tags != nil { tags = *req.Tags }
+	if req.Caption != nil {
+		entities = models.TextEntities(r.Context(), fs, post.AuthorID, *req.Caption)
+		updates = append(updates, firestore.Update{Path: "caption", Value: *req.Caption},
+			firestore.Update{Path: "entities", Value: entities})
+	}
+	if req.Tags != nil || req.Caption != nil { // caption hashtags are tags too
+		updates = append(updates, firestore.Update{Path: "tags", Value: models.NormalizeTags(append(tags, models.Hashtags(entities)...))})
+	}
+	if req.Visibility != nil {
+		if !models.Visibilities[*req.Visibility] {
+			http.Error(w, "invalid visibility", http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, firestore.Update{Path: "visibility", Value: *req.Visibility})
+	}
+	var steps []models.Step
+	if req.Steps != nil {
+		if !post.IsBeforeAfter() {
+			http.Error(w, "steps only apply to before_after posts", http.StatusBadRequest)
+			return
+		}
+		cleaned, msg, err := cleanSteps(r.Context(), *req.Steps)
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		steps = cleaned
+		updates = append(updates, firestore.Update{Path: "beforeAfter.steps", Value: steps},
+			firestore.Update{Path: "productIDs", Value: models.TaggedProductIDs(post.ProductTags, &models.BeforeAfter{Steps: steps})})
+	}
+	if req.PublishAt != nil {
+		ups, msg := scheduleUpdates(post, *req.PublishAt)
+		if msg != "" {
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		updates = append(updates, ups...)
+	}
+	if len(updates) == 0 {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	if post.IsPublished() { // drafts are not "edited" until after they go out
+		updates = append(updates,
+			firestore.Update{Path: "edited", Value: true},
+			firestore.Update{Path: "editedAt", Value: firestore.ServerTimestamp},
+		)
+	}
+	if _, err := ref.Update(r.Context(), updates); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	if req.Caption != nil {
+		res := moderation.Text(r.Context(), clf, *req.Caption)
+		if _, _, err := moderation.ApplyToPost(r.Context(), fs, post.ID, sourceCaption, res); err != nil {
+			log.Printf("edit post %s: moderation: %v", post.ID, err)
+		}
+	}
+	if req.Steps != nil {
+		res := moderation.Text(r.Context(), clf, stepsText(steps))
+		if _, _, err := moderation.ApplyToPost(r.Context(), fs, post.ID, sourceSteps, res); err != nil {
+			log.Printf("edit post %s: moderation: %v", post.ID, err)
+		}
+	}
+
+	wasShown, mentioned := post.IsLive() && post.IsPublic(), post.Entities
+	doc, err := ref.Get(r.Context())
+	if err == nil { post, err = models.PostFromDoc(doc) }
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	if shown := post.IsLive() && post.IsPublic(); post.IsPublished() && shown != wasShown { // cached feeds must follow
+		events.Publish(r.Context(), postTopic, "POST_VISIBILITY_CHANGED", map[string]string{
+			"postID": post.ID, "authorID": post.AuthorID, "visibility": post.Visibility,
+		})
+	}
+	if req.Caption != nil { announceMentions(r.Context(), post, "", post.AuthorID, post.Entities, mentioned) }
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(models.PostResponses(r.Context(), fs, []models.Post{post})[0])
+}
+
+// deletePost removes the post, everything nested under it (comments, likes,
+// …) and every storage object derived from it, then tells other services.
+func deletePost(w http.ResponseWriter, r *http.Request) {
+	post, ref, ok := loadOwnPost(w, r)
+	if !ok { return }
+
+	if err := fsutil.DeleteSubcollections(r.Context(), fs, ref); err != nil {
+		log.Printf("delete post %s: %v", post.ID, err)
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	if err := deletePostObjects(r.Context(), post); err != nil {
+		log.Printf("delete post %s: %v", post.ID, err)
+		http.Error(w, "storage err", http.StatusInternalServerError)
+		return
+	}
+	if _, err := ref.Delete(r.Context()); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+
+	events.Publish(r.Context(), postTopic, "POST_DELETED", map[string]string{
+		"postID": post.ID, "authorID": post.AuthorID,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadOwnPost fetches {id} and checks the caller is its author.
+func loadOwnPost(w http.ResponseWriter, r *http.Request) (models.Post, *firestore.DocumentRef, bool) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return models.Post{}, nil, false
+	}
+	ref := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	doc, err := ref.Get(r.Context())
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return models.Post{}, nil, false
+	}
+	post, err := models.PostFromDoc(doc)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return models.Post{}, nil, false
+	}
+	if post.AuthorID != uid {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return models.Post{}, nil, false
+	}
+	return post, ref, true
+}
+
+// deletePostObjects removes the uploads and all derived objects (thumbnails,
+// variants). They all share the "posts/<author>/<postID>" name prefix.
+func deletePostObjects(c context.Context, post models.Post) error {
+	prefix := fmt.Sprintf("posts/%s/%s", post.AuthorID, post.ID)
+	names, err := store.List(c, prefix)
+	if err != nil { return err }
+	for _, name := range names {
+		// "<postID>." or "<postID>_" – never a different post sharing the prefix
+		if rest := strings.TrimPrefix(name, prefix); !strings.HasPrefix(rest, ".") && !strings.HasPrefix(rest, "_") {
+			continue
+		}
+		err = store.Delete(c, name)
+		if err != nil && !errors.Is(err, blobstore.ErrNotExist) {
+			return fmt.Errorf("delete %s: %w", name, err)
+		}
+		signing.Forget(name)
+	}
+	return nil
+}
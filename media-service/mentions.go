@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+/* ────── mentions ─────────────────────────────────────────────────────────── */
+
+// Mentions are resolved when a caption or comment is written (see
+// models.TextEntities) and announced once the post is live: captions of
+// drafts and scheduled posts wait for announcePublished.
+
+// announceMentions publishes USER_MENTIONED for each user mentioned by
+// byUID in entities, leaving out the writer, users already mentioned in
+// before (edits) and users who cannot see the post. commentID is "" for
+// captions.
+func announceMentions(c context.Context, post models.Post, commentID, byUID string, entities, before []models.Entity) {
+	if !post.IsLive() { return }
+	skip := map[string]bool{byUID: true}
+	for _, id := range models.MentionedIDs(before) { skip[id] = true }
+
+	var names map[string]models.AuthorSummary
+	for _, uid := range models.MentionedIDs(entities) {
+		if skip[uid] { continue }
+		if ok, err := models.CanView(c, fs, uid, post); err != nil || !ok { continue }
+		if names == nil { names, _ = models.LoadAuthors(c, fs, []string{byUID}) }
+		events.Publish(c, postTopic, "USER_MENTIONED", map[string]string{
+			"mentionedID": uid, "postID": post.ID, "authorID": post.AuthorID, "commentID": commentID,
+			"mentionedBy": byUID, "mentionedByName": names[byUID].Username,
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package main
+
+/* ────── moderation ───────────────────────────────────────────────────────── */
+
+// Captions and comments are classified synchronously when written; media
+// is classified by video-processing-service once processed. Blocked posts
+// drop out of IsLive and so out of every feed and listing; blocked comments
+// are only shown to their author.
+
+// Moderation sources, see models.Moderation.
+const (
+	sourceCaption = "caption"
+	sourceSteps   = "steps" // before/after step notes
+	sourceText    = "text"
+)
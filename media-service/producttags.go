@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── product tags ─────────────────────────────────────────────────────── */
+
+type productTagsRequest struct {
+	Tags []models.ProductTag `json:"tags"`
+}
+
+// setProductTags replaces the post's product tags. Only the author may tag.
+func setProductTags(w http.ResponseWriter, r *http.Request) {
+	post, ref, ok := loadOwnPost(w, r)
+	if !ok { return }
+
+	var req productTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if msg := validateProductTags(post, req.Tags); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	ids := models.ProductIDs(req.Tags)
+	products, err := models.LoadProducts(r.Context(), fs, ids)
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	for _, id := range ids {
+		if _, ok := products[id]; !ok {
+			http.Error(w, "unknown product "+id, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.Tags == nil { req.Tags = []models.ProductTag{} }
+	tagged := models.TaggedProductIDs(req.Tags, post.BeforeAfter) // step products stay listed
+	if _, err := ref.Update(r.Context(), []firestore.Update{
+		{Path: "productTags", Value: req.Tags},
+		{Path: "productIDs", Value: tagged},
+	}); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+
+	post.ProductTags, post.ProductIDs = req.Tags, tagged
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(models.PostResponses(r.Context(), fs, []models.Post{post})[0].ProductTags)
+}
+
+func validateProductTags(post models.Post, tags []models.ProductTag) string {
+	if len(tags) > models.MaxProductTags { return fmt.Sprintf("at most %d product tags", models.MaxProductTags) }
+	items := post.Items()
+	for i, t := range tags {
+		if t.ProductID == "" { return fmt.Sprintf("tag %d: productID required", i) }
+		if t.MediaIndex < 0 || t.MediaIndex >= len(items) { return fmt.Sprintf("tag %d: no media item %d", i, t.MediaIndex) }
+		if t.X < 0 || t.X > 1 || t.Y < 0 || t.Y > 1 { return fmt.Sprintf("tag %d: x/y must be within 0..1", i) }
+		isVideo := items[t.MediaIndex].Type == "video"
+		if isVideo && (t.AtSeconds == nil || *t.AtSeconds < 0) {
+			return fmt.Sprintf("tag %d: atSeconds required for video items", i)
+		}
+		if !isVideo && t.AtSeconds != nil { return fmt.Sprintf("tag %d: atSeconds only applies to videos", i) }
+	}
+	return ""
+}
+
+// maxProductPostBatches bounds the queries behind one productPosts page.
+const maxProductPostBatches = 5
+
+// productPosts lists live posts featuring a product that the caller may
+// see, newest first. Visibility is only known after reading, so batches are
+// read until the page is full; at most maxProductPostBatches of them, so a
+// product tagged mostly in posts hidden from the caller costs a bounded
+// scan and yields a short page that still carries a cursor.
+func productPosts(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	col := fs.Collection("posts")
+	limit := paging.Limit(r, 20, 50)
+	q, err := paging.StartAfter(r, col, col.Where("productIDs", "array-contains", chi.URLParam(r, "id")).
+		OrderBy("timestamp", firestore.Desc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+
+	posts := make([]models.Post, 0, limit)
+	cursor := ""
+	for batch := 0; batch < maxProductPostBatches; batch++ {
+		docs, err := q.Documents(r.Context()).GetAll()
+		if err != nil {
+			http.Error(w, "db read err", http.StatusInternalServerError)
+			return
+		}
+		live := make([]models.Post, 0, len(docs))
+		for _, d := range docs {
+			p, err := models.PostFromDoc(d)
+			if err != nil || !p.IsLive() { continue }
+			live = append(live, p)
+		}
+		visible := map[string]models.Post{}
+		for _, p := range models.FilterVisible(r.Context(), fs, uid, live) { visible[p.ID] = p }
+
+		n := 0 // documents consumed by this page
+		for _, d := range docs {
+			if len(posts) == limit { break }
+			n++
+			if p, ok := visible[d.Ref.ID]; ok { posts = append(posts, p) }
+		}
+		cursor = ""
+		if n < len(docs) || len(docs) == limit { cursor = docs[n-1].Ref.ID } // more may follow
+		if cursor == "" || len(posts) == limit { break }
+		q = q.StartAfter(docs[n-1])
+	}
+
+	page := paging.Page[models.PostResponse]{Items: models.PostResponses(r.Context(), fs, posts)}
+	models.Personalize(r.Context(), fs, uid, page.Items)
+	page.NextCursor = cursor
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
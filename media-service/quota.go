@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/* ────── daily upload quotas ──────────────────────────────────────────────── */
+
+// Per user and UTC day. Media items and their declared sizes are charged
+// when a post is created, so nothing is uploaded past the quota; uploads
+// are signed for at most the declared size and finalize checks it again.
+const (
+	maxDailyUploads = 100     // media items
+	maxDailyBytes   = 5 << 30 // 5 GiB
+)
+
+// Counters live at users/{uid}/quotas/uploads-<yyyy-mm-dd>; expireAt lets a
+// Firestore TTL policy clear out old days.
+func quotaRef(uid string, day time.Time) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("quotas").Doc("uploads-" + day.Format("2006-01-02"))
+}
+
+type uploadQuota struct {
+	Items int64 `firestore:"items"`
+	Bytes int64 `firestore:"bytes"`
+}
+
+// quotaError is a request that would exceed today's quota.
+type quotaError struct{ msg string }
+
+func (e *quotaError) Error() string { return e.msg }
+
+// check returns a *quotaError if items more uploads of bytes in total would
+// exceed the day's quota on top of q.
+func (q uploadQuota) check(items int, bytes int64) error {
+	if q.Items+int64(items) > maxDailyUploads {
+		return &quotaError{fmt.Sprintf("daily upload quota exceeded: %d of %d media items used today", q.Items, maxDailyUploads)}
+	}
+	if q.Bytes+bytes > maxDailyBytes {
+		return &quotaError{fmt.Sprintf("daily upload quota exceeded: %d of %d bytes used today", q.Bytes, int64(maxDailyBytes))}
+	}
+	return nil
+}
+
+// quotaLedger keeps the daily counters. reserve charges uploads against
+// uid's quota for day, or returns a *quotaError without charging anything;
+// release hands a reservation back.
+type quotaLedger interface {
+	reserve(c context.Context, uid string, day time.Time, items int, bytes int64) error
+	release(c context.Context, uid string, day time.Time, items int, bytes int64) error
+}
+
+var quotas quotaLedger = firestoreQuotas{}
+
+// withUploadQuota reserves items uploads of bytes in total for uid and runs
+// create. If create fails the reservation is released, so a request that
+// created nothing costs no quota.
+func withUploadQuota(c context.Context, l quotaLedger, uid string, items int, bytes int64, create func() error) error {
+	day := time.Now().UTC()
+	if err := l.reserve(c, uid, day, items, bytes); err != nil { return err }
+	if err := create(); err != nil {
+		if rerr := l.release(context.WithoutCancel(c), uid, day, items, bytes); rerr != nil {
+			log.Printf("quota %s: release %d uploads: %v", uid, items, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+type firestoreQuotas struct{}
+
+func (firestoreQuotas) reserve(c context.Context, uid string, day time.Time, items int, bytes int64) error {
+	ref := quotaRef(uid, day)
+	return fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		var q uploadQuota
+		doc, err := tx.Get(ref)
+		switch {
+		case status.Code(err) == codes.NotFound:
+		case err != nil:
+			return err
+		default:
+			if err := doc.DataTo(&q); err != nil { return err }
+		}
+		if err := q.check(items, bytes); err != nil { return err }
+		return tx.Set(ref, map[string]any{
+			"items":    firestore.Increment(items),
+			"bytes":    firestore.Increment(bytes),
+			"expireAt": endOfDay(day).Add(24 * time.Hour),
+		}, firestore.MergeAll)
+	})
+}
+
+func (firestoreQuotas) release(c context.Context, uid string, day time.Time, items int, bytes int64) error {
+	_, err := quotaRef(uid, day).Update(c, []firestore.Update{
+		{Path: "items", Value: firestore.Increment(-items)},
+		{Path: "bytes", Value: firestore.Increment(-bytes)},
+	})
+	return err
+}
+
+// writeQuotaErr answers 429 with a Retry-After pointing at the next UTC day.
+func writeQuotaErr(w http.ResponseWriter, err *quotaError) {
+	retry := time.Until(endOfDay(time.Now().UTC()))
+	w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())+1))
+	http.Error(w, err.msg, http.StatusTooManyRequests)
+}
+
+func endOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+}
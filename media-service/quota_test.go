@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memQuotas is an in-memory quotaLedger keyed by user and day.
+type memQuotas map[string]uploadQuota
+
+func (m memQuotas) key(uid string, day time.Time) string { return uid + "/" + day.Format("2006-01-02") }
+
+func (m memQuotas) reserve(_ context.Context, uid string, day time.Time, items int, bytes int64) error {
+	q := m[m.key(uid, day)]
+	if err := q.check(items, bytes); err != nil { return err }
+	m[m.key(uid, day)] = uploadQuota{Items: q.Items + int64(items), Bytes: q.Bytes + bytes}
+	return nil
+}
+
+func (m memQuotas) release(_ context.Context, uid string, day time.Time, items int, bytes int64) error {
+	q := m[m.key(uid, day)]
+	m[m.key(uid, day)] = uploadQuota{Items: q.Items - int64(items), Bytes: q.Bytes - bytes}
+	return nil
+}
+
+func TestWithUploadQuota(t *testing.T) {
+	errWrite := errors.New("db write failed")
+	tests := []struct {
+		name      string
+		used      uploadQuota
+		items     int
+		bytes     int64
+		createErr error
+		wantErr   error // nil, errWrite, or any *quotaError
+		wantQuota bool  // whether quota errors are expected
+		wantUsed  uploadQuota
+		wantCalls int
+	}{
+		{"created", uploadQuota{}, 2, 1000, nil, nil, false, uploadQuota{Items: 2, Bytes: 1000}, 1},
+		{"write fails", uploadQuota{Items: 3, Bytes: 500}, 2, 1000, errWrite, errWrite, false, uploadQuota{Items: 3, Bytes: 500}, 1},
+		{"too many items", uploadQuota{Items: maxDailyUploads}, 1, 1, nil, nil, true, uploadQuota{Items: maxDailyUploads}, 0},
+		{"too many bytes", uploadQuota{Bytes: maxDailyBytes - 10}, 1, 11, nil, nil, true, uploadQuota{Bytes: maxDailyBytes - 10}, 0},
+		{"exactly full", uploadQuota{Items: maxDailyUploads - 1, Bytes: maxDailyBytes - 10}, 1, 10, nil, nil, false, uploadQuota{Items: maxDailyUploads, Bytes: maxDailyBytes}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := memQuotas{}
+			today := time.Now().UTC()
+			l[l.key("u1", today)] = tt.used
+			calls := 0
+			err := withUploadQuota(context.Background(), l, "u1", tt.items, tt.bytes, func() error { calls++; return tt.createErr })
+
+			var qe *quotaError
+			switch {
+			case tt.wantQuota && !errors.As(err, &qe):
+				t.Errorf("err = %v, want a quota error", err)
+			case !tt.wantQuota && !errors.Is(err, tt.wantErr):
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls { t.Errorf("create called %d times, want %d", calls, tt.wantCalls) }
+			if got := l[l.key("u1", today)]; got != tt.wantUsed { t.Errorf("quota = %+v, want %+v", got, tt.wantUsed) }
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/counters"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── saved posts ──────────────────────────────────────────────────────── */
+
+// A save is users/{uid}/saves/{postID}; it lists the collections the post
+// was filed under, each of which also holds an item doc (collections.go).
+
+type saveDoc struct {
+	PostID      string    `firestore:"postID"`
+	Collections []string  `firestore:"collections"`
+	Timestamp   time.Time `firestore:"timestamp"`
+}
+
+type saveRequest struct {
+	CollectionID string `json:"collectionID"` // optional
+}
+
+type saveResponse struct {
+	Saved       bool     `json:"saved"`
+	Collections []string `json:"collections"`
+}
+
+// savePost bookmarks a post, optionally filing it under one of the caller's
+// collections. Saving twice is a no-op.
+func savePost(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	var req saveRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+	}
+
+	save, err := saveTx(r.Context(), uid, chi.URLParam(r, "id"), req.CollectionID)
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saveResponse{Saved: true, Collections: save.Collections})
+}
+
+// unsavePost removes the bookmark and takes the post out of every
+// collection it was filed under.
+func unsavePost(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	postRef := fs.Collection("posts").Doc(chi.URLParam(r, "id"))
+	saveRef := models.SaveRef(fs, uid, postRef.ID)
+
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		sdoc, err := tx.Get(saveRef)
+		if status.Code(err) == codes.NotFound { return nil } // not saved
+		if err != nil { return err }
+		var save saveDoc
+		if err := sdoc.DataTo(&save); err != nil { return err }
+
+		cols := make([]*firestore.DocumentSnapshot, 0, len(save.Collections))
+		for _, cid := range save.Collections {
+			cdoc, err := tx.Get(collectionsCol(uid).Doc(cid))
+			if status.Code(err) == codes.NotFound { continue }
+			if err != nil { return err }
+			cols = append(cols, cdoc)
+		}
+		pdoc, err := tx.Get(postRef)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+
+		if err := tx.Delete(saveRef); err != nil { return err }
+		for _, cdoc := range cols {
+			if err := tx.Delete(cdoc.Ref.Collection("posts").Doc(postRef.ID)); err != nil { return err }
+			if err := tx.Update(cdoc.Ref, detachUpdates(cdoc, postRef.ID)); err != nil { return err }
+		}
+		if err == nil && pdoc.Exists() {
+			return counters.Add(tx, postRef, counters.Shards(pdoc), "saveCount", -1)
+		}
+		return nil
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(saveResponse{Saved: false, Collections: []string{}})
+}
+
+// saveTx saves postID for uid and, when cid is set, files it under that
+// collection; both steps are idempotent. saveCount only moves on the first
+// save.
+func saveTx(c context.Context, uid, postID, cid string) (saveDoc, error) {
+	postRef := fs.Collection("posts").Doc(postID)
+	saveRef := models.SaveRef(fs, uid, postID)
+	var colRef *firestore.DocumentRef
+	if cid != "" { colRef = collectionsCol(uid).Doc(cid) }
+
+	var save saveDoc
+	err := fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		pdoc, err := tx.Get(postRef)
+		if err != nil { return err }
+		post, err := models.PostFromDoc(pdoc)
+		if err != nil { return err }
+		if err := checkVisible(c, uid, post); err != nil { return err }
+
+		sdoc, err := tx.Get(saveRef)
+		exists := err == nil
+		switch {
+		case exists:
+			if err := sdoc.DataTo(&save); err != nil { return err }
+		case status.Code(err) == codes.NotFound:
+			save = saveDoc{PostID: postID, Collections: []string{}, Timestamp: time.Now().UTC()}
+		default:
+			return err
+		}
+		if colRef != nil {
+			if _, err := tx.Get(colRef); err != nil { return err }
+		}
+
+		file := colRef != nil && !slices.Contains(save.Collections, cid)
+		if file { save.Collections = append(save.Collections, cid) }
+		if !exists || file {
+			if err := tx.Set(saveRef, save); err != nil { return err }
+		}
+		if !exists {
+			if err := counters.Add(tx, postRef, counters.Shards(pdoc), "saveCount", 1); err != nil { return err }
+		}
+		if file {
+			if err := tx.Set(colRef.Collection("posts").Doc(postID), collectionItem{PostID: postID, AddedAt: time.Now().UTC()}); err != nil {
+				return err
+			}
+			return tx.Update(colRef, []firestore.Update{
+				{Path: "postCount", Value: firestore.Increment(1)},
+				{Path: "lastPostID", Value: postID},
+				{Path: "updatedAt", Value: firestore.ServerTimestamp},
+			})
+		}
+		return nil
+	})
+	return save, err
+}
+
+// listSaved pages through everything the caller saved, newest first.
+func listSaved(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return
+	}
+	saves := fs.Collection("users").Doc(uid).Collection("saves")
+	limit := paging.Limit(r, 20, 50)
+	q, err := paging.StartAfter(r, saves, saves.OrderBy("timestamp", firestore.Desc).Limit(limit))
+	if err != nil {
+		http.Error(w, "bad cursor", http.StatusBadRequest)
+		return
+	}
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs { ids = append(ids, d.Ref.ID) }
+	posts := models.FilterVisible(r.Context(), fs, uid, livePosts(r.Context(), ids))
+	page := paging.Page[models.PostResponse]{Items: models.PostResponses(r.Context(), fs, posts)}
+	models.Personalize(r.Context(), fs, uid, page.Items)
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// livePosts loads posts by ID in the given order, skipping deleted and
+// unpublished ones.
+func livePosts(c context.Context, ids []string) []models.Post {
+	out := []models.Post{}
+	if len(ids) == 0 { return out }
+	refs := make([]*firestore.DocumentRef, 0, len(ids))
+	for _, id := range ids { refs = append(refs, fs.Collection("posts").Doc(id)) }
+	docs, err := fs.GetAll(c, refs)
+	if err != nil { return out }
+	for _, d := range docs {
+		if !d.Exists() { continue }
+		if p, err := models.PostFromDoc(d); err == nil && p.IsLive() { out = append(out, p) }
+	}
+	return out
+}
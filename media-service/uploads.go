@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+/* ────── upload sessions ──────────────────────────────────────────────────── */
+
+// Small files go up with a single signed PUT. Items the client marks
+// resumable, or declares larger than resumableThreshold, get a resumable
+// session instead so long videos survive flaky mobile networks, as long as
+// the store has them (GCS does; the local and S3 stores fall back to PUT).
+const (
+	resumableThreshold = 32 << 20 // 32 MiB
+	uploadURLTTL       = 15 * time.Minute
+)
+
+// Session URIs are upload credentials, so they are kept out of the post doc
+// at posts/{id}/uploads/{index} and dropped on finalize.
+func uploadSessionRef(postID string, idx int) *firestore.DocumentRef {
+	return fs.Collection("posts").Doc(postID).Collection("uploads").Doc(strconv.Itoa(idx))
+}
+
+type uploadSession struct {
+	SessionURI string    `firestore:"sessionURI"`
+	CreatedAt  time.Time `firestore:"createdAt"`
+}
+
+// uploadTarget tells the client how to upload one media item: either a
+// signed PUT URL, sent with exactly Headers, or a resumable session URI.
+type uploadTarget struct {
+	Index      int               `json:"index"`
+	UploadURL  string            `json:"uploadURL,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	SessionURI string            `json:"sessionURI,omitempty"`
+	Resumable  bool              `json:"resumable"`
+}
+
+// allowedMedia maps each accepted media type to its file extensions and the
+// Content-Type uploads are signed for. Anything else is refused up front.
+// HEIC is left out: finalize cannot sniff it and would reject it anyway.
+var allowedMedia = map[string]map[string]string{
+	"image": {"jpg": "image/jpeg", "jpeg": "image/jpeg", "png": "image/png", "webp": "image/webp"},
+	"video": {"mp4": "video/mp4"},
+}
+
+// contentTypeOf is the Content-Type an item is uploaded with.
+func contentTypeOf(item models.MediaItem) string {
+	return allowedMedia[item.Type][strings.ToLower(strings.TrimPrefix(path.Ext(item.Path), "."))]
+}
+
+// newUploadTarget prepares the upload of item idx of a post, opening and
+// recording a resumable session when asked to. Signed PUTs are bound to the
+// item's Content-Type and size limit; resumable uploads only to the type,
+// their size is enforced by finalize.
+func newUploadTarget(c context.Context, r *http.Request, postID string, idx int, item models.MediaItem, resumable bool) (uploadTarget, error) {
+	rs, ok := store.(blobstore.Resumable)
+	resumable = resumable && ok
+	t := uploadTarget{Index: idx, Resumable: resumable}
+	ct, limit := contentTypeOf(item), uploadLimit(item)
+	if !resumable {
+		url, headers, err := signing.UploadURL(item.Path, ct, limit, uploadURLTTL)
+		t.UploadURL, t.Headers = url, headers
+		return t, err
+	}
+	session, err := rs.StartResumable(c, item.Path, ct, r.Header.Get("Origin"))
+	if err != nil { return t, err }
+	if _, err := uploadSessionRef(postID, idx).Set(c, uploadSession{SessionURI: session, CreatedAt: time.Now().UTC()}); err != nil {
+		return t, err
+	}
+	t.SessionURI = session
+	return t, nil
+}
+
+type uploadStatusResponse struct {
+	SessionURI    string `json:"sessionURI"`
+	BytesReceived int64  `json:"bytesReceived"`
+	Complete      bool   `json:"complete"`
+}
+
+// uploadStatus reports how far a resumable upload got, so the client knows
+// where to resume. 410 means the session is gone and a new one is needed.
+func uploadStatus(w http.ResponseWriter, r *http.Request) {
+	post, _, idx, ok := loadPendingItem(w, r)
+	if !ok { return }
+
+	doc, err := uploadSessionRef(post.ID, idx).Get(r.Context())
+	if err != nil {
+		http.Error(w, "no upload session", http.StatusNotFound)
+		return
+	}
+	var s uploadSession
+	if err := doc.DataTo(&s); err != nil {
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+	rs, ok := store.(blobstore.Resumable)
+	if !ok {
+		http.Error(w, "upload session expired", http.StatusGone)
+		return
+	}
+	received, complete, err := rs.ResumableStatus(r.Context(), s.SessionURI)
+	if errors.Is(err, blobstore.ErrSessionExpired) {
+		http.Error(w, "upload session expired", http.StatusGone)
+		return
+	}
+	if err != nil {
+		log.Printf("upload status %s/%d: %v", post.ID, idx, err)
+		http.Error(w, "storage err", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(uploadStatusResponse{SessionURI: s.SessionURI, BytesReceived: received, Complete: complete})
+}
+
+// restartUpload opens a fresh resumable session for one item, replacing an
+// expired or abandoned one, or signs a new PUT where the store has no
+// sessions. The upload starts again from byte 0.
+func restartUpload(w http.ResponseWriter, r *http.Request) {
+	post, item, idx, ok := loadPendingItem(w, r)
+	if !ok { return }
+
+	t, err := newUploadTarget(r.Context(), r, post.ID, idx, item, true)
+	if err != nil {
+		log.Printf("restart upload %s/%d: %v", post.ID, idx, err)
+		http.Error(w, "upload session err", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(t)
+}
+
+// loadPendingItem resolves {id}/{idx} to one media item of the caller's own
+// post that is still waiting for its upload.
+func loadPendingItem(w http.ResponseWriter, r *http.Request) (models.Post, models.MediaItem, int, bool) {
+	post, _, ok := loadOwnPost(w, r)
+	if !ok { return post, models.MediaItem{}, 0, false }
+	if post.Status != models.StatusPendingUpload {
+		http.Error(w, "post already "+post.Status, http.StatusConflict)
+		return post, models.MediaItem{}, 0, false
+	}
+	items := post.Items()
+	idx, err := strconv.Atoi(chi.URLParam(r, "idx"))
+	if err != nil || idx < 0 || idx >= len(items) {
+		http.Error(w, "no such media item", http.StatusNotFound)
+		return post, models.MediaItem{}, 0, false
+	}
+	return post, items[idx], idx, true
+}
+
+// dropUploadSessions forgets all session URIs of a post once its media is
+// in place.
+func dropUploadSessions(c context.Context, postID string) error {
+	return fsutil.DeleteCollection(c, fs, fs.Collection("posts").Doc(postID).Collection("uploads"))
+}
@@ -61,3 +61,5 @@ require (
 	google.golang.org/grpc v1.71.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 )
+
+replace github.com/oguzkopan/cosmetics-social-backend/shared => ../shared
@@ -15,6 +15,7 @@ import (
 	"github.com/go-redis/redis/v8"
 
 	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
 )
 
 var (
@@ -57,7 +58,10 @@ func globalFeed(w http.ResponseWriter, r *http.Request) {
 		Limit(50).Documents(r.Context()).GetAll()
 
 	posts := make([]map[string]any, 0, len(docs))
-	for _, d := range docs { posts = append(posts, d.Data()) }
+	for _, d := range docs {
+		// shared cache: public posts only
+		if p, err := models.PostFromDoc(d); err == nil && p.IsLive() && p.IsPublic() { posts = append(posts, d.Data()) }
+	}
 	respondAndCache(w, r, cacheKey, posts, 5*time.Minute)
 }
 
@@ -76,7 +80,8 @@ func followingFeed(w http.ResponseWriter, r *http.Request) {
 	ids := make([]string, 0, len(followDocs))
 	for _, d := range followDocs { ids = append(ids, d.Ref.ID) }
 
-	var posts []map[string]any
+	var candidates []models.Post
+	data := map[string]map[string]any{}
 	for _, chunk := range chunks(ids, 10) {
 		q := fs.Collection("posts").Where("authorID", "in", chunk).
 			OrderBy("timestamp", firestore.Desc).Limit(50)
@@ -84,9 +89,14 @@ func followingFeed(w http.ResponseWriter, r *http.Request) {
 		for {
 			doc, err := iter.Next()
 			if err != nil { break }
-			posts = append(posts, doc.Data())
+			p, err := models.PostFromDoc(doc)
+			if err != nil || !p.IsLive() { continue }
+			candidates = append(candidates, p)
+			data[p.ID] = doc.Data()
 		}
 	}
+	var posts []map[string]any
+	for _, p := range models.FilterVisible(r.Context(), fs, uid, candidates) { posts = append(posts, data[p.ID]) }
 	sort.Slice(posts, func(i, j int) bool {
 		ti, _ := posts[i]["timestamp"].(time.Time)
 		tj, _ := posts[j]["timestamp"].(time.Time)
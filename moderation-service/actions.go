@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+)
+
+/* ────── actions ──────────────────────────────────────────────────────────── */
+
+// Dismissing or removing a post or comment sets a moderator override on its
+// moderation state, so the classifiers do not overrule the decision until
+// the content is edited. Messages are not stored by this backend and
+// profiles cannot be taken down, so for those only warn and suspend do
+// anything. Suspensions disable the Firebase account and are lifted by
+// liftSuspensions once they run out, or by a moderator.
+
+const (
+	actionDismiss   = "dismiss"
+	actionRemove    = "remove_content"
+	actionWarn      = "warn"
+	actionSuspend   = "suspend"
+	actionReinstate = "reinstate" // a moderator ended a suspension
+	actionLift      = "lift"      // a suspension ran out
+)
+
+var resolveActions = map[string]bool{actionDismiss: true, actionRemove: true, actionWarn: true, actionSuspend: true}
+
+const maxSuspendDays = 365
+
+// systemModerator is the moderatorID of decisions nobody made by hand.
+const systemModerator = "system"
+
+// standing is users/{uid}/private/moderation, away from the profile
+// document its owner can write.
+type standing struct {
+	Warnings       int64     `firestore:"warnings"                 json:"warnings"`
+	LastWarnedAt   time.Time `firestore:"lastWarnedAt,omitempty"   json:"lastWarnedAt,omitempty"`
+	Suspensions    int64     `firestore:"suspensions"              json:"suspensions"`
+	Suspended      bool      `firestore:"suspended"                json:"suspended"`
+	SuspendedUntil time.Time `firestore:"suspendedUntil,omitempty" json:"suspendedUntil,omitempty"` // zero: until reinstated
+}
+
+func standingRef(uid string) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("private").Doc("moderation")
+}
+
+// loadStanding reads uid's record; users never acted on have none.
+func loadStanding(c context.Context, uid string) standing {
+	var s standing
+	doc, err := standingRef(uid).Get(c)
+	if err != nil { return s }
+	_ = doc.DataTo(&s)
+	return s
+}
+
+// suspensionRef is suspensions/{uid}, which only timed suspensions have;
+// liftSuspensions scans them.
+func suspensionRef(uid string) *firestore.DocumentRef {
+	return fs.Collection("suspensions").Doc(uid)
+}
+
+// effect is what an action changed, for announceAction.
+type effect struct {
+	post        *models.Post // the post whose override changed
+	shownBefore bool         // whether it was live and public
+	until       time.Time    // end of a timed suspension
+}
+
+// applyAction carries out req on item's target inside tx.
+func applyAction(tx *firestore.Transaction, item moderation.QueueItem, mod string, req resolveRequest) (effect, error) {
+	switch req.Action {
+	case actionDismiss:
+		return overrideContent(tx, item, models.ModerationAllow)
+
+	case actionRemove:
+		if item.Kind != moderation.KindPost && item.Kind != moderation.KindComment {
+			return effect{}, status.Error(codes.InvalidArgument, "a "+item.Kind+" cannot be removed; warn or suspend instead")
+		}
+		return overrideContent(tx, item, models.ModerationBlock)
+
+	case actionWarn:
+		return effect{}, tx.Set(standingRef(item.AuthorID), map[string]any{
+			"warnings": firestore.Increment(1), "lastWarnedAt": firestore.ServerTimestamp,
+		}, firestore.MergeAll)
+
+	case actionSuspend:
+		var eff effect
+		data := map[string]any{"suspended": true, "suspensions": firestore.Increment(1), "suspendedUntil": firestore.Delete}
+		if req.SuspendDays > 0 {
+			eff.until = time.Now().UTC().AddDate(0, 0, req.SuspendDays)
+			data["suspendedUntil"] = eff.until
+			if err := tx.Set(suspensionRef(item.AuthorID), map[string]any{"until": eff.until, "by": mod}); err != nil { return eff, err }
+		} else if err := tx.Delete(suspensionRef(item.AuthorID)); err != nil {
+			return eff, err
+		}
+		return eff, tx.Set(standingRef(item.AuthorID), data, firestore.MergeAll)
+	}
+	return effect{}, status.Error(codes.InvalidArgument, "invalid action")
+}
+
+// overrideContent sets the moderator decision on a post or comment. Targets
+// deleted in the meantime are left alone.
+func overrideContent(tx *firestore.Transaction, item moderation.QueueItem, decision string) (effect, error) {
+	var eff effect
+	switch item.Kind {
+	case moderation.KindPost:
+		ref := fs.Collection("posts").Doc(item.PostID)
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound { return eff, nil }
+		if err != nil { return eff, err }
+		post, err := models.PostFromDoc(doc)
+		if err != nil { return eff, err }
+		eff.shownBefore = post.IsLive() && post.IsPublic()
+		post.Moderation = post.Moderation.Overridden(decision)
+		eff.post = &post
+		return eff, tx.Update(ref, []firestore.Update{{Path: "moderation", Value: post.Moderation}})
+
+	case moderation.KindComment:
+		ref := models.CommentRef(fs, item.PostID, item.CommentID)
+		doc, err := tx.Get(ref)
+		if status.Code(err) == codes.NotFound { return eff, nil }
+		if err != nil { return eff, err }
+		cm, err := models.CommentFromDoc(doc)
+		if err != nil { return eff, err }
+		return eff, tx.Update(ref, []firestore.Update{{Path: "moderation", Value: cm.Moderation.Overridden(decision)}})
+	}
+	return eff, nil
+}
+
+// announceAction tells feeds and the affected user about a decision.
+func announceAction(c context.Context, item moderation.QueueItem, req resolveRequest, eff effect) {
+	if p := eff.post; p != nil && (p.IsLive() && p.IsPublic()) != eff.shownBefore {
+		events.Publish(c, postTopic, "POST_VISIBILITY_CHANGED", map[string]string{
+			"postID": p.ID, "authorID": p.AuthorID, "visibility": p.Visibility,
+		})
+	}
+
+	target := map[string]string{"userID": item.AuthorID, "kind": item.Kind, "postID": item.PostID, "commentID": item.CommentID}
+	switch req.Action {
+	case actionRemove:
+		events.Publish(c, postTopic, "CONTENT_REMOVED", target)
+	case actionWarn:
+		events.Publish(c, socialTopic, "USER_WARNED", target)
+	case actionSuspend:
+		target["until"] = ""
+		if !eff.until.IsZero() { target["until"] = models.FormatTime(eff.until) }
+		events.Publish(c, socialTopic, "USER_SUSPENDED", target)
+	}
+}
+
+/* ────── suspensions ──────────────────────────────────────────────────────── */
+
+// reinstateUser ends a suspension early, or an indefinite one.
+func reinstateUser(w http.ResponseWriter, r *http.Request) {
+	mod, ok := requireModerator(w, r)
+	if !ok { return }
+	uid := chi.URLParam(r, "id")
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	_ = json.NewDecoder(r.Body).Decode(&req) // the note is optional
+	if !loadStanding(r.Context(), uid).Suspended { http.Error(w, "not suspended", http.StatusConflict); return }
+
+	if err := auth.SetDisabled(r.Context(), uid, false); err != nil {
+		log.Printf("reinstate %s: %v", uid, err)
+		http.Error(w, "auth err", http.StatusBadGateway)
+		return
+	}
+	if err := unsuspend(r.Context(), uid, actionReinstate, mod, strings.TrimSpace(req.Note)); err != nil {
+		http.Error(w, "db write err", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// liftSuspensions re-enables accounts whose suspension ran out. Triggered
+// by Cloud Scheduler.
+func liftSuspensions(w http.ResponseWriter, r *http.Request) {
+	docs, err := fs.Collection("suspensions").
+		Where("until", "<=", time.Now()).
+		OrderBy("until", firestore.Asc).
+		Limit(500).Documents(r.Context()).GetAll()
+	if err != nil {
+		log.Printf("lift suspensions: %v", err)
+		http.Error(w, "db read err", http.StatusInternalServerError)
+		return
+	}
+
+	n := 0
+	for _, d := range docs {
+		uid := d.Ref.ID
+		if err := auth.SetDisabled(r.Context(), uid, false); err != nil {
+			log.Printf("lift suspension %s: %v", uid, err)
+			continue
+		}
+		if err := unsuspend(r.Context(), uid, actionLift, systemModerator, ""); err != nil {
+			log.Printf("lift suspension %s: %v", uid, err)
+			continue
+		}
+		n++
+	}
+	writeJSON(w, http.StatusOK, map[string]int{"lifted": n})
+}
+
+// unsuspend records that uid's account is enabled again.
+func unsuspend(c context.Context, uid, action, by, note string) error {
+	return fs.RunTransaction(c, func(c context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(standingRef(uid), map[string]any{"suspended": false, "suspendedUntil": firestore.Delete}, firestore.MergeAll); err != nil { return err }
+		if err := tx.Delete(suspensionRef(uid)); err != nil { return err }
+		return writeAudit(tx, auditEntry{Action: action, ModeratorID: by, Note: note, Kind: moderation.KindUser, TargetID: uid, AuthorID: uid})
+	})
+}
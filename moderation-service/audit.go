@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── audit trail ──────────────────────────────────────────────────────── */
+
+// Every moderator decision is appended to moderationAudit and never
+// changed afterwards. Entries are written in the same transaction as the
+// decision, so the trail cannot miss one.
+
+// auditEntry is moderationAudit/{auto}.
+type auditEntry struct {
+	ID          string    `firestore:"-"                json:"id"`
+	ItemID      string    `firestore:"itemID,omitempty" json:"itemID,omitempty"` // "" for reinstatements and lifts
+	Action      string    `firestore:"action"           json:"action"`
+	ModeratorID string    `firestore:"moderatorID"      json:"moderatorID"`
+	Note        string    `firestore:"note,omitempty"   json:"note,omitempty"`
+	Kind        string    `firestore:"kind"             json:"kind"`
+	TargetID    string    `firestore:"targetID"         json:"targetID"`
+	PostID      string    `firestore:"postID,omitempty" json:"postID,omitempty"`
+	AuthorID    string    `firestore:"authorID"         json:"authorID"` // the user the decision was about
+	At          time.Time `firestore:"at"               json:"at"`
+}
+
+func auditCol() *firestore.CollectionRef { return fs.Collection("moderationAudit") }
+
+func auditFromDoc(doc *firestore.DocumentSnapshot) auditEntry {
+	var e auditEntry
+	_ = doc.DataTo(&e)
+	e.ID = doc.Ref.ID
+	return e
+}
+
+func writeAudit(tx *firestore.Transaction, e auditEntry) error {
+	e.At = time.Now().UTC()
+	return tx.Create(auditCol().NewDoc(), e)
+}
+
+// listAudit lists decisions newest first, optionally only those on
+// ?itemID=, by ?moderatorID= or about ?userID=.
+func listAudit(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok { return }
+
+	col := auditCol()
+	q := col.Query
+	for param, field := range map[string]string{"itemID": "itemID", "moderatorID": "moderatorID", "userID": "authorID"} {
+		if v := r.URL.Query().Get(param); v != "" { q = q.Where(field, "==", v) }
+	}
+	limit := paging.Limit(r, 20, 100)
+	q, err := paging.StartAfter(r, col, q.OrderBy("at", firestore.Desc).Limit(limit))
+	if err != nil { http.Error(w, "bad cursor", http.StatusBadRequest); return }
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+
+	page := paging.Page[auditEntry]{Items: make([]auditEntry, 0, len(docs))}
+	for _, d := range docs { page.Items = append(page.Items, auditFromDoc(d)) }
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	writeJSON(w, http.StatusOK, page)
+}
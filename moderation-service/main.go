@@ -0,0 +1,117 @@
+// cmd/moderation-service/main.go
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+/* ────── env vars ─────────────────────────────────────────────────────────── */
+
+var (
+	projectID   = mustEnv("GOOGLE_CLOUD_PROJECT")
+	port        = "8080"
+	postTopic   = mustEnv("POST_EVENTS_TOPIC")   // "post-events"
+	socialTopic = mustEnv("SOCIAL_EVENTS_TOPIC") // "social-events"
+)
+
+/* ────── globals (initialised in main) ────────────────────────────────────── */
+
+var (
+	ctx context.Context
+	fs  *firestore.Client
+)
+
+/* ────── main ─────────────────────────────────────────────────────────────── */
+
+func main() {
+	ctx = context.Background()
+
+	var err error
+	if fs, err = firestore.NewClient(ctx, projectID); err != nil {
+		log.Fatalf("firestore: %v", err)
+	}
+	if err = auth.Init(ctx); err != nil {
+		log.Fatalf("auth init: %v", err)
+	}
+	if err = events.Init(ctx, projectID); err != nil {
+		log.Fatalf("pubsub init: %v", err)
+	}
+	internal, err := auth.Invoker()
+	if err != nil {
+		log.Fatalf("internal routes: %v", err)
+	}
+	// media URL signing so moderators can look at reported posts
+	store, err := blobstore.FromEnv(ctx)
+	if err != nil { log.Fatalf("storage: %v", err) }
+	if !store.CanSign() { log.Fatal("storage: no signing credentials") }
+	signing.Init(store)
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger, middleware.RequestID, middleware.Timeout(15*time.Second))
+
+	// reporting (signed in)
+	r.Post("/reports", createReport)
+
+	// moderators
+	r.Get("/moderation/queue", listQueue)
+	r.Get("/moderation/queue/{id}", getQueueItem)
+	r.Post("/moderation/queue/{id}/claim", claimItem)
+	r.Delete("/moderation/queue/{id}/claim", releaseItem)
+	r.Post("/moderation/queue/{id}/resolve", resolveItem)
+	r.Get("/moderation/audit", listAudit)
+	r.Post("/moderation/users/{id}/reinstate", reinstateUser)
+
+	// Cloud Scheduler
+	r.With(internal).Post("/internal/lift-suspensions", liftSuspensions)
+
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("moderation-svc OK")) })
+
+	log.Printf("moderation-service listening on :%s", port)
+	log.Fatal(http.ListenAndServe(":"+port, r))
+}
+
+/* ────── helpers ─────────────────────────────────────────────────────────── */
+
+// requireModerator answers 401/403 unless the caller carries the moderator
+// custom claim.
+func requireModerator(w http.ResponseWriter, r *http.Request) (string, bool) {
+	uid, err := auth.VerifyClaim(r.Context(), r, auth.ClaimModerator)
+	if errors.Is(err, auth.ErrMissingClaim) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, "unauth", http.StatusUnauthorized)
+		return "", false
+	}
+	return uid, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func mustEnv(k string) string {
+	v := os.Getenv(k)
+	if v == "" {
+		log.Fatalf("missing env %s", k)
+	}
+	return v
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/paging"
+)
+
+/* ────── queue ────────────────────────────────────────────────────────────── */
+
+// Moderators work the queue by claiming an item, which keeps others off it
+// for claimTTL, and resolving it with one of the actions in actions.go.
+// Claims that run out are free for anyone to take over.
+
+const claimTTL = 30 * time.Minute
+
+const maxResolveNote = 1000
+
+// listQueue lists queue items, most reported first with ?sort=reports and
+// most recently updated otherwise. ?status=open (the default) includes
+// claimed items so abandoned claims stay in sight.
+func listQueue(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok { return }
+
+	col := fs.Collection("moderationQueue")
+	q := col.Query
+	switch st := r.URL.Query().Get("status"); st {
+	case "", moderation.QueueOpen:
+		q = q.Where("status", "in", []string{moderation.QueueOpen, moderation.QueueClaimed})
+	case moderation.QueueClaimed, moderation.QueueResolved, moderation.QueueCleared:
+		q = q.Where("status", "==", st)
+	default:
+		http.Error(w, "invalid status", http.StatusBadRequest)
+		return
+	}
+	switch kind := r.URL.Query().Get("kind"); kind {
+	case "":
+	case moderation.KindPost, moderation.KindComment, moderation.KindMessage, moderation.KindUser:
+		q = q.Where("kind", "==", kind)
+	default:
+		http.Error(w, "invalid kind", http.StatusBadRequest)
+		return
+	}
+	if r.URL.Query().Get("sort") == "reports" { q = q.OrderBy("reportCount", firestore.Desc) }
+	q = q.OrderBy("updatedAt", firestore.Desc)
+
+	limit := paging.Limit(r, 20, 100)
+	q, err := paging.StartAfter(r, col, q.Limit(limit))
+	if err != nil { http.Error(w, "bad cursor", http.StatusBadRequest); return }
+	docs, err := q.Documents(r.Context()).GetAll()
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+
+	page := paging.Page[moderation.QueueItem]{Items: make([]moderation.QueueItem, 0, len(docs))}
+	for _, d := range docs {
+		it, err := moderation.ItemFromDoc(d)
+		if err != nil { continue }
+		page.Items = append(page.Items, it)
+	}
+	if len(docs) == limit { page.NextCursor = docs[len(docs)-1].Ref.ID }
+	writeJSON(w, http.StatusOK, page)
+}
+
+// queueItemDetail is everything a moderator needs to decide on an item.
+type queueItemDetail struct {
+	moderation.QueueItem
+	Post        *models.PostResponse    `json:"post,omitempty"`
+	Comment     *models.CommentResponse `json:"comment,omitempty"`
+	Author      models.AuthorSummary    `json:"author"`
+	Standing    standing                `json:"standing"` // the author's record
+	Reports     []reportDoc             `json:"reports"`  // newest first
+	Audit       []auditEntry            `json:"audit"`    // decisions on this item, newest first
+	DuplicateOf *models.Duplicate       `json:"duplicateOf,omitempty"` // reposts: the earlier post the media matches
+	Original    *models.PostResponse    `json:"original,omitempty"`
+}
+
+// getQueueItem shows an item with its target, reports and history.
+func getQueueItem(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok { return }
+
+	ref := moderation.ItemRef(fs, chi.URLParam(r, "id"))
+	doc, err := ref.Get(r.Context())
+	if err != nil { http.Error(w, "not found", http.StatusNotFound); return }
+	it, err := moderation.ItemFromDoc(doc)
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+	out := queueItemDetail{QueueItem: it, Reports: []reportDoc{}, Audit: []auditEntry{}}
+
+	c := r.Context()
+	switch it.Kind {
+	case moderation.KindPost:
+		if d, err := fs.Collection("posts").Doc(it.PostID).Get(c); err == nil {
+			if post, err := models.PostFromDoc(d); err == nil {
+				out.Post, out.DuplicateOf = &models.PostResponses(c, fs, []models.Post{post})[0], post.DuplicateOf
+			}
+		}
+		if out.DuplicateOf != nil {
+			if d, err := fs.Collection("posts").Doc(out.DuplicateOf.PostID).Get(c); err == nil {
+				if orig, err := models.PostFromDoc(d); err == nil { out.Original = &models.PostResponses(c, fs, []models.Post{orig})[0] }
+			}
+		}
+	case moderation.KindComment:
+		if d, err := models.CommentRef(fs, it.PostID, it.CommentID).Get(c); err == nil {
+			if cm, err := models.CommentFromDoc(d); err == nil { out.Comment = &models.CommentResponses(c, fs, "", []models.Comment{cm})[0] }
+		}
+	}
+	if authors, err := models.LoadAuthors(c, fs, []string{it.AuthorID}); err == nil { out.Author = authors[it.AuthorID] }
+	out.Standing = loadStanding(c, it.AuthorID)
+
+	reports, err := ref.Collection("reports").OrderBy("updatedAt", firestore.Desc).Limit(50).Documents(c).GetAll()
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+	for _, d := range reports {
+		var rep reportDoc
+		if d.DataTo(&rep) == nil { out.Reports = append(out.Reports, rep) }
+	}
+	trail, err := auditCol().Where("itemID", "==", it.ID).OrderBy("at", firestore.Desc).Limit(50).Documents(c).GetAll()
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+	for _, d := range trail { out.Audit = append(out.Audit, auditFromDoc(d)) }
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+/* ────── claims ───────────────────────────────────────────────────────────── */
+
+// claimItem takes an item for the caller. Claiming an item one already
+// holds renews the claim.
+func claimItem(w http.ResponseWriter, r *http.Request) {
+	mod, ok := requireModerator(w, r)
+	if !ok { return }
+
+	var item moderation.QueueItem
+	ref := moderation.ItemRef(fs, chi.URLParam(r, "id"))
+	err := fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if item, err = moderation.ItemFromDoc(doc); err != nil { return err }
+		if item.Status != moderation.QueueOpen && item.Status != moderation.QueueClaimed {
+			return status.Error(codes.FailedPrecondition, "item is "+item.Status)
+		}
+		if item.Status == moderation.QueueClaimed && item.ClaimedBy != mod && time.Since(item.ClaimedAt) < claimTTL {
+			return status.Error(codes.FailedPrecondition, "claimed by another moderator")
+		}
+		item.Status, item.ClaimedBy, item.ClaimedAt = moderation.QueueClaimed, mod, time.Now().UTC()
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: item.Status},
+			{Path: "claimedBy", Value: item.ClaimedBy},
+			{Path: "claimedAt", Value: item.ClaimedAt},
+		})
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	writeJSON(w, http.StatusOK, item)
+}
+
+// releaseItem gives up the caller's claim without deciding.
+func releaseItem(w http.ResponseWriter, r *http.Request) {
+	mod, ok := requireModerator(w, r)
+	if !ok { return }
+
+	ref := moderation.ItemRef(fs, chi.URLParam(r, "id"))
+	err := fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		item, err := moderation.ItemFromDoc(doc)
+		if err != nil { return err }
+		if item.Status != moderation.QueueClaimed || item.ClaimedBy != mod {
+			return status.Error(codes.FailedPrecondition, "not claimed by you")
+		}
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: moderation.QueueOpen},
+			{Path: "claimedBy", Value: firestore.Delete},
+			{Path: "claimedAt", Value: firestore.Delete},
+		})
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkClaim fails unless mod holds the claim on item. A claim that ran out
+// still counts as long as nobody took it over.
+func checkClaim(item moderation.QueueItem, mod string) error {
+	if item.Status != moderation.QueueClaimed || item.ClaimedBy != mod {
+		return status.Error(codes.FailedPrecondition, "claim the item first")
+	}
+	return nil
+}
+
+/* ────── resolving ────────────────────────────────────────────────────────── */
+
+type resolveRequest struct {
+	Action      string `json:"action"` // dismiss | remove_content | warn | suspend
+	Note        string `json:"note"`
+	SuspendDays int    `json:"suspendDays"` // suspend only; 0 suspends until reinstated
+}
+
+// resolveItem closes a claimed item with an action and records the
+// decision in the audit trail.
+func resolveItem(w http.ResponseWriter, r *http.Request) {
+	mod, ok := requireModerator(w, r)
+	if !ok { return }
+
+	var req resolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", http.StatusBadRequest); return }
+	if !resolveActions[req.Action] { http.Error(w, "invalid action", http.StatusBadRequest); return }
+	req.Note = strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(req.Note) > maxResolveNote { http.Error(w, "note too long", http.StatusBadRequest); return }
+	if req.SuspendDays < 0 || req.SuspendDays > maxSuspendDays || (req.SuspendDays != 0 && req.Action != actionSuspend) {
+		http.Error(w, "invalid suspendDays", http.StatusBadRequest)
+		return
+	}
+
+	ref := moderation.ItemRef(fs, chi.URLParam(r, "id"))
+	doc, err := ref.Get(r.Context())
+	if err != nil { http.Error(w, "not found", http.StatusNotFound); return }
+	item, err := moderation.ItemFromDoc(doc)
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return }
+	if !fsutil.WriteTxErr(w, checkClaim(item, mod)) { return }
+
+	// Firebase Auth cannot join the transaction: disable the account first
+	// and turn it back on if the decision cannot be recorded.
+	wasSuspended := loadStanding(r.Context(), item.AuthorID).Suspended
+	if req.Action == actionSuspend && !wasSuspended {
+		if err := auth.SetDisabled(r.Context(), item.AuthorID, true); err != nil {
+			log.Printf("suspend %s: %v", item.AuthorID, err)
+			http.Error(w, "auth err", http.StatusBadGateway)
+			return
+		}
+	}
+
+	var eff effect
+	var res moderation.Resolution
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if item, err = moderation.ItemFromDoc(doc); err != nil { return err }
+		if err := checkClaim(item, mod); err != nil { return err }
+
+		if eff, err = applyAction(tx, item, mod, req); err != nil { return err }
+		res = moderation.Resolution{Action: req.Action, Note: req.Note, ModeratorID: mod, At: time.Now().UTC()}
+		if err := tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: moderation.QueueResolved},
+			{Path: "resolution", Value: res},
+			{Path: "updatedAt", Value: firestore.ServerTimestamp},
+		}); err != nil { return err }
+		return writeAudit(tx, auditEntry{
+			ItemID: item.ID, Action: req.Action, ModeratorID: mod, Note: req.Note,
+			Kind: item.Kind, TargetID: item.TargetID, PostID: item.PostID, AuthorID: item.AuthorID,
+		})
+	})
+	if err != nil && req.Action == actionSuspend && !wasSuspended {
+		if err := auth.SetDisabled(r.Context(), item.AuthorID, false); err != nil { log.Printf("undo suspend %s: %v", item.AuthorID, err) }
+	}
+	if !fsutil.WriteTxErr(w, err) { return }
+
+	announceAction(r.Context(), item, req, eff)
+	item.Status, item.Resolution = moderation.QueueResolved, &res
+	writeJSON(w, http.StatusOK, item)
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/fsutil"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+)
+
+/* ────── reports ──────────────────────────────────────────────────────────── */
+
+// Users report posts, comments and profiles. Every target has one queue
+// item (see moderation.ItemID), shared with the classifiers,
+// and each reporter's report sits below it at reports/{uid}: reporting again
+// only replaces the reason and note, so reportCount counts people rather
+// than taps. A report on a resolved or cleared item reopens it. Direct
+// messages cannot be reported yet: this backend does not keep them, so
+// their sender and text could only come from the reporter.
+
+var reportReasons = map[string]bool{
+	"spam": true, "scam": true, "harassment": true, "hate": true, "nudity": true, "violence": true,
+	"self_harm": true, "counterfeit": true, "misinformation": true, "other": true,
+}
+
+const maxReportNote = 500
+
+type reportRequest struct {
+	TargetType string `json:"targetType"` // post | comment | user
+	TargetID   string `json:"targetID"`
+	PostID     string `json:"postID"` // comments: the post they belong to
+	Reason     string `json:"reason"`
+	Note       string `json:"note"`
+}
+
+// reportDoc is moderationQueue/{id}/reports/{reporterUID}.
+type reportDoc struct {
+	ReporterID string    `firestore:"reporterID"     json:"reporterID"`
+	Reason     string    `firestore:"reason"         json:"reason"`
+	Note       string    `firestore:"note,omitempty" json:"note,omitempty"`
+	CreatedAt  time.Time `firestore:"createdAt"      json:"createdAt"`
+	UpdatedAt  time.Time `firestore:"updatedAt"      json:"updatedAt"`
+}
+
+// createReport files or updates the caller's report on a target.
+func createReport(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", http.StatusUnauthorized); return }
+
+	var req reportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TargetID == "" {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if !reportReasons[req.Reason] { http.Error(w, "invalid reason", http.StatusBadRequest); return }
+	req.Note = strings.TrimSpace(req.Note)
+	if utf8.RuneCountInString(req.Note) > maxReportNote { http.Error(w, "note too long", http.StatusBadRequest); return }
+
+	item, ok := reportTarget(w, r, uid, req)
+	if !ok { return }
+	if item.AuthorID == uid { http.Error(w, "cannot report yourself", http.StatusBadRequest); return }
+
+	ref := moderation.ItemRef(fs, item.ID)
+	mine := ref.Collection("reports").Doc(uid)
+	err = fs.RunTransaction(r.Context(), func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+		prev, err := tx.Get(mine)
+		if err != nil && status.Code(err) != codes.NotFound { return err }
+
+		now := time.Now().UTC()
+		rep := reportDoc{ReporterID: uid, Reason: req.Reason, Note: req.Note, CreatedAt: now, UpdatedAt: now}
+		data := map[string]any{
+			"kind": item.Kind, "targetID": item.TargetID, "postID": item.PostID, "commentID": item.CommentID,
+			"authorID": item.AuthorID, "updatedAt": firestore.ServerTimestamp,
+		}
+		if prev.Exists() {
+			var old reportDoc
+			if err := prev.DataTo(&old); err != nil { return err }
+			rep.CreatedAt = old.CreatedAt
+			if old.Reason != req.Reason {
+				data["reasons"] = map[string]any{old.Reason: firestore.Increment(-1), req.Reason: firestore.Increment(1)}
+			}
+		} else {
+			data["reportCount"] = firestore.Increment(1)
+			data["reasons"] = map[string]any{req.Reason: firestore.Increment(1)}
+		}
+
+		switch {
+		case !doc.Exists():
+			data["status"], data["labels"] = moderation.QueueOpen, []string{}
+		default:
+			cur, err := moderation.ItemFromDoc(doc)
+			if err != nil { return err }
+			if cur.Status == moderation.QueueResolved || cur.Status == moderation.QueueCleared {
+				data["status"], data["claimedBy"], data["claimedAt"], data["resolution"] =
+					moderation.QueueOpen, firestore.Delete, firestore.Delete, firestore.Delete
+			}
+		}
+		if err := tx.Set(ref, data, firestore.MergeAll); err != nil { return err }
+		return tx.Set(mine, rep)
+	})
+	if !fsutil.WriteTxErr(w, err) { return }
+	writeJSON(w, http.StatusAccepted, map[string]any{"reported": true, "itemID": item.ID})
+}
+
+// reportTarget checks that the reported target exists and that uid can see
+// it, and names its queue item.
+func reportTarget(w http.ResponseWriter, r *http.Request, uid string, req reportRequest) (moderation.QueueItem, bool) {
+	item := moderation.QueueItem{Kind: req.TargetType, TargetID: req.TargetID}
+	switch req.TargetType {
+	case moderation.KindPost:
+		post, ok := visiblePost(w, r, uid, req.TargetID)
+		if !ok { return item, false }
+		item.PostID, item.AuthorID = post.ID, post.AuthorID
+
+	case moderation.KindComment:
+		if req.PostID == "" { http.Error(w, "postID required", http.StatusBadRequest); return item, false }
+		if _, ok := visiblePost(w, r, uid, req.PostID); !ok { return item, false }
+		doc, err := models.CommentRef(fs, req.PostID, req.TargetID).Get(r.Context())
+		if err != nil { http.Error(w, "not found", http.StatusNotFound); return item, false }
+		cm, err := models.CommentFromDoc(doc)
+		if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return item, false }
+		if cm.Moderation.Blocked() { http.Error(w, "not found", http.StatusNotFound); return item, false }
+		item.PostID, item.CommentID, item.AuthorID = req.PostID, cm.ID, cm.AuthorID
+
+	case moderation.KindUser:
+		if !userExists(w, r, req.TargetID) { return item, false }
+		item.AuthorID = req.TargetID
+
+	default:
+		http.Error(w, "invalid targetType", http.StatusBadRequest)
+		return item, false
+	}
+	item.ID = moderation.ItemID(item.Kind, item.TargetID, item.PostID)
+	return item, true
+}
+
+// visiblePost loads a live post uid may see; anything else is a 404.
+func visiblePost(w http.ResponseWriter, r *http.Request, uid, postID string) (models.Post, bool) {
+	doc, err := fs.Collection("posts").Doc(postID).Get(r.Context())
+	if err != nil { http.Error(w, "not found", http.StatusNotFound); return models.Post{}, false }
+	post, err := models.PostFromDoc(doc)
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return models.Post{}, false }
+	if !post.IsLive() { http.Error(w, "not found", http.StatusNotFound); return models.Post{}, false }
+	visible, err := models.CanView(r.Context(), fs, uid, post)
+	if err != nil { http.Error(w, "db read err", http.StatusInternalServerError); return models.Post{}, false }
+	if !visible { http.Error(w, "not found", http.StatusNotFound); return models.Post{}, false }
+	return post, true
+}
+
+func userExists(w http.ResponseWriter, r *http.Request, uid string) bool {
+	if _, err := fs.Collection("users").Doc(uid).Get(r.Context()); err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
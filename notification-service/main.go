@@ -64,9 +64,25 @@ func handlePubSub(w http.ResponseWriter, r *http.Request) {
 			"New follower",
 			"Someone started following you")
 	case "POST_LIKED":
-		sendPushToPostOwner(payload, " liked your post")
+		sendPushToPostOwner(payload, "likedBy", " liked your post")
 	case "POST_COMMENTED":
-		sendPushToPostOwner(payload, " commented on your post")
+		sendPushToPostOwner(payload, "commentedBy", " commented on your post")
+	case "USER_MENTIONED":
+		actor := "Someone"
+		if payload["mentionedByName"] != "" { actor = "@" + payload["mentionedByName"] }
+		where := "a post"
+		if payload["commentID"] != "" { where = "a comment" }
+		sendPush(payload["mentionedID"], "CosmeticSocial", actor+" mentioned you in "+where)
+	case "CONTENT_REMOVED":
+		what := "Your post"
+		if payload["kind"] == "comment" { what = "Your comment" }
+		sendPush(payload["userID"], "CosmeticSocial", what+" was removed for breaking our community guidelines")
+	case "USER_WARNED":
+		sendPush(payload["userID"], "CosmeticSocial", "You received a warning for breaking our community guidelines")
+	case "USER_SUSPENDED":
+		body := "Your account has been suspended"
+		if u := payload["until"]; len(u) >= 10 { body += " until " + u[:10] } // the date part of RFC 3339
+		sendPush(payload["userID"], "CosmeticSocial", body)
 	case "MESSAGE_SENT":
 		sendPush(payload["recipientID"],
 			"New message",
@@ -87,10 +103,16 @@ func sendPush(uid, title, body string) {
 	_, _ = fcm.Send(ctx, msg)
 }
 
-func sendPushToPostOwner(p map[string]string, suffix string) {
+// sendPushToPostOwner notifies the post's author about actorKey's action;
+// the payload may carry the actor's username under actorKey+"Name".
+func sendPushToPostOwner(p map[string]string, actorKey, suffix string) {
 	postID := p["postID"]
 	if postID == "" { return }
-	doc, _ := fs.Collection("posts").Doc(postID).Get(ctx)
-	owner := doc.Data()["authorID"].(string)
-	sendPush(owner, "CosmeticSocial", p["likedBy"]+suffix)
+	doc, err := fs.Collection("posts").Doc(postID).Get(ctx)
+	if err != nil || !doc.Exists() { return } // deleted since
+	owner, ok := doc.Data()["authorID"].(string)
+	if !ok || owner == p[actorKey] { return } // own post
+	actor := p[actorKey]
+	if p[actorKey+"Name"] != "" { actor = "@" + p[actorKey+"Name"] }
+	sendPush(owner, "CosmeticSocial", actor+suffix)
 }
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -25,12 +26,45 @@ func Init(ctx context.Context) error {
 // VerifyFirebaseToken extracts and validates the Firebase ID-token.
 // Returns UID on success.
 func VerifyFirebaseToken(ctx context.Context, r *http.Request) (string, error) {
-	if client == nil { return "", fmt.Errorf("auth not initialised") }
+	tok, err := verify(ctx, r)
+	if err != nil { return "", err }
+	return tok.UID, nil
+}
 
-	raw := r.Header.Get("Authorization")
-	if raw == "" { return "", fmt.Errorf("missing Authorization header") }
-	tokenString := strings.TrimPrefix(raw, "Bearer ")
-	tok, err := client.VerifyIDToken(ctx, tokenString)
+// ErrMissingClaim is returned by VerifyClaim for valid tokens that lack the
+// required custom claim – callers answer 403 rather than 401.
+var ErrMissingClaim = errors.New("missing required claim")
+
+// Custom claims set via the Admin SDK.
+const (
+	ClaimAdmin     = "admin"
+	ClaimModerator = "moderator"
+)
+
+// VerifyClaim is VerifyFirebaseToken plus a check that the boolean custom
+// claim is true. Returns UID on success.
+func VerifyClaim(ctx context.Context, r *http.Request, claim string) (string, error) {
+	tok, err := verify(ctx, r)
 	if err != nil { return "", err }
+	if ok, _ := tok.Claims[claim].(bool); !ok { return tok.UID, ErrMissingClaim }
 	return tok.UID, nil
 }
+
+// SetDisabled suspends (disabled) or reinstates a Firebase account.
+// Suspending also revokes refresh tokens, so the user is signed out once
+// their current ID token expires (within the hour).
+func SetDisabled(ctx context.Context, uid string, disabled bool) error {
+	if client == nil { return fmt.Errorf("auth not initialised") }
+	if _, err := client.UpdateUser(ctx, uid, (&auth.UserToUpdate{}).Disabled(disabled)); err != nil { return err }
+	if !disabled { return nil }
+	return client.RevokeRefreshTokens(ctx, uid)
+}
+
+func verify(ctx context.Context, r *http.Request) (*auth.Token, error) {
+	if client == nil { return nil, fmt.Errorf("auth not initialised") }
+
+	raw := r.Header.Get("Authorization")
+	if raw == "" { return nil, fmt.Errorf("missing Authorization header") }
+	tokenString := strings.TrimPrefix(raw, "Bearer ")
+	return client.VerifyIDToken(ctx, tokenString)
+}
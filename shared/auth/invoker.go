@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/idtoken"
+)
+
+// Internal endpoints (/internal/*, Pub/Sub push) are called by Cloud
+// Scheduler and Pub/Sub, not by users. Callers prove who they are with
+// either
+//
+//   - a Google-signed OIDC token for INVOKER_AUDIENCE (the service URL)
+//     issued to one of the service accounts in INVOKER_EMAILS, which is
+//     what Scheduler and push subscriptions send when configured with an
+//     OIDC service account, or
+//   - the shared secret INTERNAL_SECRET in the X-Internal-Secret header,
+//     for setups without OIDC (local development, other schedulers).
+
+// SecretHeader carries the shared secret of internal calls.
+const SecretHeader = "X-Internal-Secret"
+
+// ErrNoInvokerConfig is returned by Invoker when neither way of
+// authenticating internal calls is configured.
+var ErrNoInvokerConfig = errors.New("set INVOKER_AUDIENCE and INVOKER_EMAILS, or INTERNAL_SECRET")
+
+// Invoker returns middleware that answers 401 to internal calls that carry
+// neither a valid OIDC token nor the shared secret. Services call it from
+// main() and refuse to start on error, so the routes are never open.
+func Invoker() (func(http.Handler) http.Handler, error) {
+	audience, secret := os.Getenv("INVOKER_AUDIENCE"), os.Getenv("INTERNAL_SECRET")
+	var emails []string
+	for _, e := range strings.Split(os.Getenv("INVOKER_EMAILS"), ",") {
+		if e = strings.TrimSpace(e); e != "" { emails = append(emails, e) }
+	}
+	if (audience == "" || len(emails) == 0) && secret == "" { return nil, ErrNoInvokerConfig }
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get(SecretHeader); secret != "" && got != "" {
+				if subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1 {
+					next.ServeHTTP(w, r)
+					return
+				}
+			} else if audience != "" && len(emails) > 0 && verifyInvoker(r.Context(), r, audience, emails) == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			http.Error(w, "unauth", http.StatusUnauthorized)
+		})
+	}, nil
+}
+
+func verifyInvoker(ctx context.Context, r *http.Request, audience string, emails []string) error {
+	raw, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || raw == "" { return errors.New("missing bearer token") }
+	p, err := idtoken.Validate(ctx, raw, audience)
+	if err != nil { return err }
+	email, _ := p.Claims["email"].(string)
+	verified, _ := p.Claims["email_verified"].(bool)
+	if !verified || !slices.Contains(emails, email) { return errors.New("token not issued to an allowed invoker") }
+	return nil
+}
@@ -0,0 +1,111 @@
+// Package blobstore abstracts the media bucket so that services are not
+// tied to Google Cloud Storage.
+//
+// STORAGE_BACKEND picks the implementation:
+//
+//   - "gcs" (default): Cloud Storage, see gcs.go.
+//   - "local": a directory on disk with signed URLs served by the service
+//     itself, for running offline; see local.go.
+//   - "s3": any S3-compatible store (MinIO, AWS, R2), see s3.go.
+//
+// Object names are the same everywhere (posts/<author>/<post>_<i>.<ext>),
+// so documents written against one backend stay valid on another.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ErrNotExist is returned for objects that are not in the store.
+var ErrNotExist = errors.New("blobstore: object does not exist")
+
+// ErrNoSigner is returned by URL methods of stores set up without signing
+// credentials.
+var ErrNoSigner = errors.New("blobstore: signing not configured")
+
+// Attrs are the object metadata the services need.
+type Attrs struct {
+	Size        int64
+	ContentType string
+}
+
+// Store is an object store holding the media bucket.
+type Store interface {
+	NewReader(ctx context.Context, object string) (io.ReadCloser, error)
+	// NewRangeReader reads length bytes from offset (-1: to the end).
+	NewRangeReader(ctx context.Context, object string, offset, length int64) (io.ReadCloser, error)
+	// NewWriter creates or replaces object; the write is only complete
+	// once Close returns nil.
+	NewWriter(ctx context.Context, object, contentType string) io.WriteCloser
+	Attrs(ctx context.Context, object string) (Attrs, error)
+	// Delete removes object. A missing object is reported as ErrNotExist
+	// where the store can tell.
+	Delete(ctx context.Context, object string) error
+	// List returns the names of all objects starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// CanSign reports whether the URL methods are available.
+	CanSign() bool
+	// ReadURL is a GET URL for object that works without credentials
+	// until expires.
+	ReadURL(object string, expires time.Time) (string, error)
+	// UploadURL is a PUT URL for object, to be sent with exactly headers.
+	// Stores that can enforce it reject uploads larger than maxBytes;
+	// callers must still check the size of what arrived.
+	UploadURL(object, contentType string, maxBytes int64, expires time.Time) (url string, headers map[string]string, err error)
+}
+
+// Resumable is implemented by stores with resumable upload sessions, which
+// let clients continue an interrupted upload where it stopped.
+type Resumable interface {
+	// StartResumable opens a session for object and returns its URI.
+	// origin is the browser origin allowed to use it (CORS), "" for
+	// native clients.
+	StartResumable(ctx context.Context, object, contentType, origin string) (string, error)
+	// ResumableStatus reports how much of a session has been stored.
+	ResumableStatus(ctx context.Context, session string) (received int64, complete bool, err error)
+}
+
+// ErrSessionExpired is returned by ResumableStatus once the store no
+// longer knows the session; the client has to start over.
+var ErrSessionExpired = errors.New("upload session expired")
+
+// FromEnv opens the store named by STORAGE_BACKEND. Signing credentials are
+// optional: without them CanSign is false, which is fine for services that
+// only read and write objects. For the local store they are
+// LOCAL_STORAGE_SECRET, which every service signing URLs for it must share
+// with the one serving them.
+func FromEnv(ctx context.Context) (Store, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "gcs":
+		return NewGCS(ctx, os.Getenv("MEDIA_BUCKET"), os.Getenv("SERVICE_ACCOUNT_EMAIL"), os.Getenv("SERVICE_ACCOUNT_KEY_PATH"))
+	case "local":
+		// no default secret: anyone knowing it can sign uploads and reads
+		l, err := NewLocal(envOr("LOCAL_STORAGE_DIR", "./data/media"), envOr("LOCAL_STORAGE_URL", "http://localhost:8080/blobs"),
+			os.Getenv("LOCAL_STORAGE_SECRET"), os.Getenv("LOCAL_STORAGE_NOTIFY_URL"))
+		if err != nil { return nil, err }
+		l.notifySecret = os.Getenv("INTERNAL_SECRET") // the push endpoint checks it, see auth.Invoker
+		return l, nil
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:    os.Getenv("S3_ENDPOINT"),
+			Region:      envOr("S3_REGION", "us-east-1"),
+			Bucket:      envOr("S3_BUCKET", os.Getenv("MEDIA_BUCKET")),
+			AccessKey:   os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretKey:   os.Getenv("S3_SECRET_ACCESS_KEY"),
+			VirtualHost: os.Getenv("S3_VIRTUAL_HOST") == "true", // <bucket>.<endpoint host>
+		})
+	default:
+		return nil, fmt.Errorf("blobstore: unknown STORAGE_BACKEND %q", backend)
+	}
+}
+
+func envOr(k, def string) string {
+	if v := os.Getenv(k); v != "" { return v }
+	return def
+}
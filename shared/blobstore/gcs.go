@@ -0,0 +1,173 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCS is a Cloud Storage bucket. URLs are V4-signed with a service account
+// key.
+type GCS struct {
+	client   *storage.Client
+	bucket   string
+	accessID string
+	key      []byte
+}
+
+// contentLengthRangeHeader makes GCS refuse uploads outside "min,max" bytes.
+const contentLengthRangeHeader = "x-goog-content-length-range"
+
+// NewGCS opens bucket. accessID and keyPath are the service account used
+// for signing; leave keyPath empty to go without.
+func NewGCS(ctx context.Context, bucket, accessID, keyPath string) (*GCS, error) {
+	if bucket == "" { return nil, fmt.Errorf("blobstore: MEDIA_BUCKET not set") }
+	g := &GCS{bucket: bucket, accessID: accessID}
+	if keyPath != "" {
+		b, err := os.ReadFile(keyPath)
+		if err != nil { return nil, fmt.Errorf("blobstore: signing key: %w", err) }
+		g.key = b
+	}
+	c, err := storage.NewClient(ctx)
+	if err != nil { return nil, err }
+	g.client = c
+	return g, nil
+}
+
+func (g *GCS) obj(object string) *storage.ObjectHandle { return g.client.Bucket(g.bucket).Object(object) }
+
+func gcsErr(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) { return ErrNotExist }
+	return err
+}
+
+func (g *GCS) NewReader(ctx context.Context, object string) (io.ReadCloser, error) {
+	rc, err := g.obj(object).NewReader(ctx)
+	return rc, gcsErr(err)
+}
+
+func (g *GCS) NewRangeReader(ctx context.Context, object string, offset, length int64) (io.ReadCloser, error) {
+	rc, err := g.obj(object).NewRangeReader(ctx, offset, length)
+	return rc, gcsErr(err)
+}
+
+func (g *GCS) NewWriter(ctx context.Context, object, contentType string) io.WriteCloser {
+	w := g.obj(object).NewWriter(ctx)
+	w.ContentType = contentType
+	return w
+}
+
+func (g *GCS) Attrs(ctx context.Context, object string) (Attrs, error) {
+	a, err := g.obj(object).Attrs(ctx)
+	if err != nil { return Attrs{}, gcsErr(err) }
+	return Attrs{Size: a.Size, ContentType: a.ContentType}, nil
+}
+
+func (g *GCS) Delete(ctx context.Context, object string) error { return gcsErr(g.obj(object).Delete(ctx)) }
+
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	it := g.client.Bucket(g.bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done { return names, nil }
+		if err != nil { return nil, err }
+		names = append(names, attrs.Name)
+	}
+}
+
+func (g *GCS) CanSign() bool { return g.key != nil }
+
+func (g *GCS) sign(object string, opts storage.SignedURLOptions) (string, error) {
+	if !g.CanSign() { return "", ErrNoSigner }
+	opts.GoogleAccessID, opts.PrivateKey, opts.Scheme = g.accessID, g.key, storage.SigningSchemeV4
+	return storage.SignedURL(g.bucket, object, &opts)
+}
+
+func (g *GCS) ReadURL(object string, expires time.Time) (string, error) {
+	return g.sign(object, storage.SignedURLOptions{Method: "GET", Expires: expires})
+}
+
+// UploadURL binds the upload to contentType and to at most maxBytes; GCS
+// rejects anything else.
+func (g *GCS) UploadURL(object, contentType string, maxBytes int64, expires time.Time) (string, map[string]string, error) {
+	url, err := g.sign(object, storage.SignedURLOptions{
+		Method:      "PUT",
+		Expires:     expires,
+		ContentType: contentType,
+		Headers:     []string{fmt.Sprintf("%s:0,%d", contentLengthRangeHeader, maxBytes)},
+	})
+	headers := map[string]string{"Content-Type": contentType, contentLengthRangeHeader: fmt.Sprintf("0,%d", maxBytes)}
+	return url, headers, err
+}
+
+/* ────── resumable uploads ────────────────────────────────────────────────── */
+
+// The server opens a session with a signed POST ("x-goog-resumable: start")
+// and hands the session URI to the client, which PUTs the object in chunks
+// and can resume after a dropped connection. GCS keeps a session for a
+// week.
+
+func (g *GCS) StartResumable(ctx context.Context, object, contentType, origin string) (string, error) {
+	url, err := g.sign(object, storage.SignedURLOptions{
+		Method:      "POST",
+		Expires:     time.Now().Add(5 * time.Minute),
+		ContentType: contentType,
+		Headers:     []string{"x-goog-resumable:start"},
+	})
+	if err != nil { return "", err }
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil { return "", err }
+	req.Header.Set("x-goog-resumable", "start")
+	req.Header.Set("Content-Type", contentType)
+	if origin != "" { req.Header.Set("Origin", origin) }
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { return "", err }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("start resumable %s: %s: %s", object, resp.Status, body)
+	}
+	session := resp.Header.Get("Location")
+	if session == "" { return "", fmt.Errorf("start resumable %s: no session URI", object) }
+	return session, nil
+}
+
+func (g *GCS) ResumableStatus(ctx context.Context, session string) (received int64, complete bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, session, nil)
+	if err != nil { return 0, false, err }
+	req.Header.Set("Content-Range", "bytes */*")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { return 0, false, err }
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return 0, true, nil
+	case http.StatusPermanentRedirect: // 308 "Resume Incomplete"
+		// "Range: bytes=0-<last>"; absent while nothing has been stored
+		rng := resp.Header.Get("Range")
+		if rng == "" { return 0, false, nil }
+		_, last, ok := strings.Cut(rng, "-")
+		if !ok { return 0, false, fmt.Errorf("bad Range header %q", rng) }
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil { return 0, false, fmt.Errorf("bad Range header %q", rng) }
+		return n + 1, false, nil
+	case http.StatusNotFound, http.StatusGone:
+		return 0, false, ErrSessionExpired
+	default:
+		return 0, false, fmt.Errorf("resumable status: %s", resp.Status)
+	}
+}
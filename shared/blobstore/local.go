@@ -0,0 +1,275 @@
+package blobstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local keeps objects as files under a directory, for development and
+// integration tests without any cloud account. Signed URLs point at the
+// service that mounts Local as its handler (media-service under /blobs/)
+// and carry an HMAC over method, object, expiry and upload limits, so
+// clients exercise the same direct-upload flow as in production.
+//
+// With notifyURL set, every completed write is announced there as a GCS
+// "object finalize" Pub/Sub push, which is what video-processing-service
+// listens for; notifySecret goes along as the internal-call secret.
+type Local struct {
+	dir          string
+	base         *url.URL
+	secret       []byte
+	notifyURL    string
+	notifySecret string
+}
+
+// NewLocal stores objects under dir and signs URLs below baseURL.
+func NewLocal(dir, baseURL, secret, notifyURL string) (*Local, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil { return nil, fmt.Errorf("blobstore: LOCAL_STORAGE_URL: %w", err) }
+	if err := os.MkdirAll(dir, 0o755); err != nil { return nil, err }
+	return &Local{dir: dir, base: u, secret: []byte(secret), notifyURL: notifyURL}, nil
+}
+
+// Prefix is the path the handler expects to be mounted at, e.g. "/blobs/".
+func (l *Local) Prefix() string { return l.base.Path + "/" }
+
+func (l *Local) file(object string) (string, error) {
+	p := filepath.FromSlash(object)
+	if object == "" || !filepath.IsLocal(p) { return "", fmt.Errorf("blobstore: bad object name %q", object) }
+	return filepath.Join(l.dir, p), nil
+}
+
+func localErr(err error) error {
+	if errors.Is(err, fs.ErrNotExist) { return ErrNotExist }
+	return err
+}
+
+func (l *Local) NewReader(ctx context.Context, object string) (io.ReadCloser, error) {
+	return l.NewRangeReader(ctx, object, 0, -1)
+}
+
+func (l *Local) NewRangeReader(_ context.Context, object string, offset, length int64) (io.ReadCloser, error) {
+	name, err := l.file(object)
+	if err != nil { return nil, err }
+	f, err := os.Open(name)
+	if err != nil { return nil, localErr(err) }
+	if _, err := f.Seek(offset, io.SeekStart); err != nil { f.Close(); return nil, err }
+	if length < 0 { return f, nil }
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(f, length), f}, nil
+}
+
+// localWriter writes to a temp file next to the object and renames it into
+// place on Close, so readers never see a partial object.
+type localWriter struct {
+	l          *Local
+	object, ct string
+	tmp        *os.File
+	err        error
+}
+
+func (l *Local) NewWriter(_ context.Context, object, contentType string) io.WriteCloser {
+	return l.newWriter(object, contentType)
+}
+
+func (l *Local) newWriter(object, contentType string) *localWriter {
+	w := &localWriter{l: l, object: object, ct: contentType}
+	name, err := l.file(object)
+	if err == nil { err = os.MkdirAll(filepath.Dir(name), 0o755) }
+	if err == nil { w.tmp, err = os.CreateTemp(filepath.Dir(name), ".upload-*") }
+	w.err = err
+	return w
+}
+
+func (w *localWriter) Write(p []byte) (int, error) {
+	if w.err != nil { return 0, w.err }
+	n, err := w.tmp.Write(p)
+	if err != nil { w.err = err }
+	return n, err
+}
+
+// abort makes Close discard the temp file.
+func (w *localWriter) abort(err error) {
+	if w.err == nil { w.err = err }
+	w.Close()
+}
+
+func (w *localWriter) Close() error {
+	if w.tmp == nil { return w.err }
+	tmp := w.tmp.Name()
+	w.tmp.Close()
+	if w.err != nil { os.Remove(tmp); return w.err }
+	name, _ := w.l.file(w.object)
+	if err := os.Rename(tmp, name); err != nil { os.Remove(tmp); return err }
+	w.l.notify(w.object, w.ct)
+	return nil
+}
+
+func (l *Local) Attrs(_ context.Context, object string) (Attrs, error) {
+	name, err := l.file(object)
+	if err != nil { return Attrs{}, err }
+	fi, err := os.Stat(name)
+	if err != nil { return Attrs{}, localErr(err) }
+	// no metadata is kept; the extension is what uploads are signed for anyway
+	return Attrs{Size: fi.Size(), ContentType: mime.TypeByExtension(path.Ext(object))}, nil
+}
+
+func (l *Local) Delete(_ context.Context, object string) error {
+	name, err := l.file(object)
+	if err != nil { return err }
+	return localErr(os.Remove(name))
+}
+
+func (l *Local) List(_ context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(l.dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") { return err }
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil { return err }
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) { names = append(names, name) }
+		return nil
+	})
+	return names, err
+}
+
+/* ────── signed URLs ──────────────────────────────────────────────────────── */
+
+func (l *Local) CanSign() bool { return len(l.secret) > 0 }
+
+func (l *Local) mac(method, object string, expires int64, ct, max string) string {
+	m := hmac.New(sha256.New, l.secret)
+	fmt.Fprintf(m, "%s\n%s\n%d\n%s\n%s", method, object, expires, ct, max)
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+func (l *Local) signedURL(method, object string, expires time.Time, ct, max string) (string, error) {
+	if !l.CanSign() { return "", ErrNoSigner }
+	if _, err := l.file(object); err != nil { return "", err }
+	q := url.Values{"expires": {strconv.FormatInt(expires.Unix(), 10)}, "sig": {l.mac(method, object, expires.Unix(), ct, max)}}
+	if ct != "" { q.Set("ct", ct) }
+	if max != "" { q.Set("max", max) }
+	u := *l.base
+	u.Path += "/" + object
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (l *Local) ReadURL(object string, expires time.Time) (string, error) {
+	return l.signedURL(http.MethodGet, object, expires, "", "")
+}
+
+func (l *Local) UploadURL(object, contentType string, maxBytes int64, expires time.Time) (string, map[string]string, error) {
+	url, err := l.signedURL(http.MethodPut, object, expires, contentType, strconv.FormatInt(maxBytes, 10))
+	return url, map[string]string{"Content-Type": contentType}, err
+}
+
+// ServeHTTP serves the URLs signed by ReadURL (GET, with Range support)
+// and UploadURL (PUT). Mount it at Prefix.
+func (l *Local) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	object := strings.TrimPrefix(r.URL.Path, l.Prefix())
+	q := r.URL.Query()
+	method := r.Method
+	if method == http.MethodHead { method = http.MethodGet }
+
+	if !l.CanSign() {
+		http.Error(w, "signing not configured", http.StatusForbidden)
+		return
+	}
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		http.Error(w, "url expired", http.StatusForbidden)
+		return
+	}
+	want := l.mac(method, object, expires, q.Get("ct"), q.Get("max"))
+	if !hmac.Equal([]byte(want), []byte(q.Get("sig"))) {
+		http.Error(w, "bad signature", http.StatusForbidden)
+		return
+	}
+
+	switch method {
+	case http.MethodGet:
+		name, err := l.file(object)
+		if err != nil { http.Error(w, "bad object", http.StatusBadRequest); return }
+		f, err := os.Open(name)
+		if err != nil { http.Error(w, "not found", http.StatusNotFound); return }
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil { http.Error(w, "read err", http.StatusInternalServerError); return }
+		if ct := mime.TypeByExtension(path.Ext(object)); ct != "" { w.Header().Set("Content-Type", ct) }
+		http.ServeContent(w, r, "", fi.ModTime(), f)
+	case http.MethodPut:
+		if r.Header.Get("Content-Type") != q.Get("ct") {
+			http.Error(w, "content type mismatch", http.StatusForbidden)
+			return
+		}
+		max, _ := strconv.ParseInt(q.Get("max"), 10, 64)
+		if r.ContentLength > max {
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		wc := l.newWriter(object, q.Get("ct"))
+		n, err := io.Copy(wc, io.LimitReader(r.Body, max+1))
+		if err == nil && n > max {
+			wc.abort(errTooLarge)
+			http.Error(w, "too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			wc.abort(err)
+			http.Error(w, "write err", http.StatusInternalServerError)
+			return
+		}
+		if err := wc.Close(); err != nil { http.Error(w, "write err", http.StatusInternalServerError); return }
+		w.WriteHeader(http.StatusOK)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+var errTooLarge = errors.New("upload exceeds signed size")
+
+/* ────── notifications ────────────────────────────────────────────────────── */
+
+// notify posts the Pub/Sub push envelope GCS would send for a finalized
+// object. It runs in the background; failures are logged only, like a lost
+// notification would be.
+func (l *Local) notify(object, contentType string) {
+	if l.notifyURL == "" { return }
+	data, _ := json.Marshal(map[string]string{"bucket": "local", "name": object, "contentType": contentType})
+	body, _ := json.Marshal(map[string]any{
+		"message": map[string]any{
+			"attributes": map[string]string{"eventType": "OBJECT_FINALIZE", "objectId": object},
+			"data":       base64.StdEncoding.EncodeToString(data),
+		},
+	})
+	req, err := http.NewRequest(http.MethodPost, l.notifyURL, bytes.NewReader(body))
+	if err != nil { log.Printf("blobstore: notify %s: %v", object, err); return }
+	req.Header.Set("Content-Type", "application/json")
+	if l.notifySecret != "" { req.Header.Set("X-Internal-Secret", l.notifySecret) } // auth.SecretHeader
+	go func() {
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil { log.Printf("blobstore: notify %s: %v", object, err); return }
+		resp.Body.Close()
+	}()
+}
@@ -0,0 +1,124 @@
+package blobstore
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestLocal serves a Local store over HTTP the way media-service mounts it.
+func newTestLocal(t *testing.T, secret string) *Local {
+	t.Helper()
+	var l *Local
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { l.ServeHTTP(w, r) }))
+	t.Cleanup(srv.Close)
+	l, err := NewLocal(t.TempDir(), srv.URL+"/blobs", secret, "")
+	if err != nil { t.Fatal(err) }
+	return l
+}
+
+func do(t *testing.T, method, url string, headers map[string]string, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil { t.Fatal(err) }
+	for k, v := range headers { req.Header.Set(k, v) }
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { t.Fatal(err) }
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(b)
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	l := newTestLocal(t, "test-secret")
+	const object, content = "posts/u1/p1.jpg", "\xff\xd8\xff jpeg bytes"
+	expires := time.Now().Add(time.Minute)
+
+	up, headers, err := l.UploadURL(object, "image/jpeg", 1024, expires)
+	if err != nil { t.Fatal(err) }
+	if code, body := do(t, http.MethodPut, up, headers, content); code != http.StatusOK {
+		t.Fatalf("PUT = %d %s", code, body)
+	}
+
+	attrs, err := l.Attrs(context.Background(), object)
+	if err != nil || attrs.Size != int64(len(content)) || attrs.ContentType != "image/jpeg" {
+		t.Errorf("Attrs = %+v, %v", attrs, err)
+	}
+
+	down, err := l.ReadURL(object, expires)
+	if err != nil { t.Fatal(err) }
+	if code, body := do(t, http.MethodGet, down, nil, ""); code != http.StatusOK || body != content {
+		t.Errorf("GET = %d %q, want 200 %q", code, body, content)
+	}
+	if code, body := do(t, http.MethodGet, down, map[string]string{"Range": "bytes=4-7"}, ""); code != http.StatusPartialContent || body != content[4:8] {
+		t.Errorf("GET range = %d %q, want 206 %q", code, body, content[4:8])
+	}
+}
+
+func TestLocalRejects(t *testing.T) {
+	l := newTestLocal(t, "test-secret")
+	const object = "posts/u1/p1.jpg"
+	expires := time.Now().Add(time.Minute)
+	up, headers, err := l.UploadURL(object, "image/jpeg", 8, expires)
+	if err != nil { t.Fatal(err) }
+	down, err := l.ReadURL(object, expires)
+	if err != nil { t.Fatal(err) }
+	past, err := l.ReadURL(object, time.Now().Add(-time.Minute))
+	if err != nil { t.Fatal(err) }
+	forger, err := NewLocal(t.TempDir(), l.base.String(), "other-secret", "")
+	if err != nil { t.Fatal(err) }
+	forged, _, err := forger.UploadURL(object, "image/jpeg", 8, expires)
+	if err != nil { t.Fatal(err) }
+
+	tests := []struct {
+		name    string
+		method  string
+		url     string
+		headers map[string]string
+		body    string
+		want    int
+	}{
+		{"too large", http.MethodPut, up, headers, "123456789", http.StatusRequestEntityTooLarge},
+		{"wrong content type", http.MethodPut, up, map[string]string{"Content-Type": "image/png"}, "1234", http.StatusForbidden},
+		{"read URL used to upload", http.MethodPut, down, headers, "1234", http.StatusForbidden},
+		{"upload URL used to read", http.MethodGet, up, nil, "", http.StatusForbidden},
+		{"tampered object", http.MethodGet, strings.Replace(down, "p1.jpg", "p2.jpg", 1), nil, "", http.StatusForbidden},
+		{"expired", http.MethodGet, past, nil, "", http.StatusForbidden},
+		{"other secret", http.MethodPut, forged, headers, "1234", http.StatusForbidden},
+		{"missing object", http.MethodGet, down, nil, "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, body := do(t, tt.method, tt.url, tt.headers, tt.body); code != tt.want {
+				t.Errorf("%s = %d %s, want %d", tt.method, code, body, tt.want)
+			}
+		})
+	}
+	if _, err := l.Attrs(context.Background(), object); err != ErrNotExist {
+		t.Errorf("rejected uploads left an object: %v", err)
+	}
+}
+
+func TestLocalWithoutSecret(t *testing.T) {
+	l := newTestLocal(t, "")
+	if l.CanSign() { t.Error("CanSign without a secret") }
+	if _, err := l.ReadURL("posts/u1/p1.jpg", time.Now().Add(time.Minute)); err != ErrNoSigner {
+		t.Errorf("ReadURL without a secret: %v, want ErrNoSigner", err)
+	}
+
+	// nor may a URL signed with the empty key be accepted
+	const object = "posts/u1/p1.jpg"
+	expires := time.Now().Add(time.Minute).Unix()
+	q := url.Values{"expires": {strconv.FormatInt(expires, 10)}, "ct": {"image/jpeg"}, "max": {"8"},
+		"sig": {l.mac(http.MethodPut, object, expires, "image/jpeg", "8")}}
+	forged := l.base.String() + "/" + object + "?" + q.Encode()
+	if code, body := do(t, http.MethodPut, forged, map[string]string{"Content-Type": "image/jpeg"}, "1234"); code != http.StatusForbidden {
+		t.Errorf("PUT signed with the empty key = %d %s, want 403", code, body)
+	}
+}
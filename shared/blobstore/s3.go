@@ -0,0 +1,279 @@
+package blobstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config points at an S3-compatible bucket, addressed path-style
+// (<endpoint>/<bucket>/<object>) by default so that MinIO and other
+// self-hosted stores work without wildcard DNS. VirtualHost addresses it as
+// <bucket>.<endpoint host>/<object> instead, which AWS prefers.
+type S3Config struct {
+	Endpoint    string // e.g. "http://localhost:9000", "https://s3.eu-west-1.amazonaws.com"
+	Region      string
+	Bucket      string
+	AccessKey   string
+	SecretKey   string
+	VirtualHost bool
+}
+
+// S3 talks to the bucket over plain HTTP with SigV4 query signing, so the
+// same presigning covers both the service's own requests and the URLs
+// handed to clients.
+type S3 struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// requestTTL is how long the service's own presigned requests are valid.
+const requestTTL = 15 * time.Minute
+
+// NewS3 checks cfg; no request is made until the store is used.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" { return nil, fmt.Errorf("blobstore: S3_ENDPOINT and S3_BUCKET must be set") }
+	u, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || u.Host == "" { return nil, fmt.Errorf("blobstore: bad S3_ENDPOINT %q", cfg.Endpoint) }
+	return &S3{cfg: cfg, endpoint: u, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+func (s *S3) do(ctx context.Context, method, object string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	var signed []string
+	if header.Get("Content-Type") != "" { signed = append(signed, "content-type") }
+	now := time.Now()
+	u, err := s.presign(now, method, object, query, header, signed, now.Add(requestTTL))
+	if err != nil { return nil, err }
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil { return nil, err }
+	for k, v := range header { req.Header[k] = v }
+	if body != nil { req.ContentLength = size }
+	return s.client.Do(req)
+}
+
+// s3Err turns an unexpected response into an error, closing its body.
+func s3Err(resp *http.Response, object string) error {
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound { return ErrNotExist }
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s: %s", resp.Request.Method, object, resp.Status, msg)
+}
+
+func (s *S3) NewReader(ctx context.Context, object string) (io.ReadCloser, error) {
+	return s.NewRangeReader(ctx, object, 0, -1)
+}
+
+func (s *S3) NewRangeReader(ctx context.Context, object string, offset, length int64) (io.ReadCloser, error) {
+	h := http.Header{}
+	switch {
+	case length == 0:
+		return io.NopCloser(strings.NewReader("")), nil
+	case length > 0:
+		h.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		h.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := s.do(ctx, http.MethodGet, object, nil, h, nil, 0)
+	if err != nil { return nil, err }
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent { return nil, s3Err(resp, object) }
+	return resp.Body, nil
+}
+
+// s3Writer spools to a temp file: a plain PUT needs the length up front.
+type s3Writer struct {
+	s          *S3
+	ctx        context.Context
+	object, ct string
+	tmp        *os.File
+	err        error
+}
+
+func (s *S3) NewWriter(ctx context.Context, object, contentType string) io.WriteCloser {
+	w := &s3Writer{s: s, ctx: ctx, object: object, ct: contentType}
+	w.tmp, w.err = os.CreateTemp("", "s3-upload-*")
+	return w
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	if w.err != nil { return 0, w.err }
+	n, err := w.tmp.Write(p)
+	if err != nil { w.err = err }
+	return n, err
+}
+
+func (w *s3Writer) Close() error {
+	if w.tmp == nil { return w.err }
+	defer os.Remove(w.tmp.Name())
+	defer w.tmp.Close()
+	if w.err != nil { return w.err }
+	size, err := w.tmp.Seek(0, io.SeekCurrent)
+	if err != nil { return err }
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil { return err }
+
+	h := http.Header{}
+	if w.ct != "" { h.Set("Content-Type", w.ct) }
+	resp, err := w.s.do(w.ctx, http.MethodPut, w.object, nil, h, w.tmp, size)
+	if err != nil { return err }
+	if resp.StatusCode != http.StatusOK { return s3Err(resp, w.object) }
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Attrs(ctx context.Context, object string) (Attrs, error) {
+	resp, err := s.do(ctx, http.MethodHead, object, nil, http.Header{}, nil, 0)
+	if err != nil { return Attrs{}, err }
+	if resp.StatusCode != http.StatusOK { return Attrs{}, s3Err(resp, object) }
+	resp.Body.Close()
+	return Attrs{Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// Delete succeeds for missing objects too; S3 does not tell them apart.
+func (s *S3) Delete(ctx context.Context, object string) error {
+	resp, err := s.do(ctx, http.MethodDelete, object, nil, http.Header{}, nil, 0)
+	if err != nil { return err }
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK { return s3Err(resp, object) }
+	resp.Body.Close()
+	return nil
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (s *S3) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" { q.Set("continuation-token", token) }
+		resp, err := s.do(ctx, http.MethodGet, "", q, http.Header{}, nil, 0)
+		if err != nil { return nil, err }
+		if resp.StatusCode != http.StatusOK { return nil, s3Err(resp, prefix+"*") }
+		var res listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+		if err != nil { return nil, err }
+		for _, c := range res.Contents { names = append(names, c.Key) }
+		if !res.IsTruncated || res.NextContinuationToken == "" { return names, nil }
+		token = res.NextContinuationToken
+	}
+}
+
+/* ────── signed URLs ──────────────────────────────────────────────────────── */
+
+func (s *S3) CanSign() bool { return s.cfg.AccessKey != "" && s.cfg.SecretKey != "" }
+
+func (s *S3) ReadURL(object string, expires time.Time) (string, error) {
+	return s.presign(time.Now(), http.MethodGet, object, nil, nil, nil, expires)
+}
+
+// UploadURL binds the upload to contentType only: a presigned PUT cannot
+// carry a size limit, so finalize's size check is what enforces maxBytes.
+func (s *S3) UploadURL(object, contentType string, _ int64, expires time.Time) (string, map[string]string, error) {
+	h := http.Header{"Content-Type": {contentType}}
+	url, err := s.presign(time.Now(), http.MethodPut, object, nil, h, []string{"content-type"}, expires)
+	return url, map[string]string{"Content-Type": contentType}, err
+}
+
+// presign builds a SigV4 query-signed URL as of now. signed lists the
+// (lower-case) headers besides host the request must carry with the values
+// in header.
+func (s *S3) presign(now time.Time, method, object string, query url.Values, header http.Header, signed []string, expires time.Time) (string, error) {
+	if !s.CanSign() { return "", ErrNoSigner }
+	now = now.UTC()
+	ttl := int64(expires.Sub(now).Seconds())
+	if ttl < 1 { ttl = 1 }
+	if ttl > 7*24*3600 { ttl = 7 * 24 * 3600 } // SigV4 maximum
+
+	date, stamp := now.Format("20060102"), now.Format("20060102T150405Z")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	signed = append([]string{"host"}, signed...)
+	sort.Strings(signed)
+
+	q := url.Values{}
+	for k, v := range query { q[k] = v }
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+scope)
+	q.Set("X-Amz-Date", stamp)
+	q.Set("X-Amz-Expires", strconv.FormatInt(ttl, 10))
+	q.Set("X-Amz-SignedHeaders", strings.Join(signed, ";"))
+
+	host, path := s.endpoint.Host, "/"+s.cfg.Bucket
+	if s.cfg.VirtualHost { host, path = s.cfg.Bucket+"."+host, "" }
+	if object != "" || path == "" { path += "/" + object }
+	var headers strings.Builder
+	for _, h := range signed {
+		v := host
+		if h != "host" { v = strings.TrimSpace(header.Get(h)) }
+		headers.WriteString(h + ":" + v + "\n")
+	}
+	canonical := strings.Join([]string{
+		method, uriEncode(path, false), canonicalQuery(q), headers.String(), strings.Join(signed, ";"), "UNSIGNED-PAYLOAD",
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	sig := hex.EncodeToString(hmacSHA256(signingKey(s.cfg.SecretKey, date, s.cfg.Region, "s3"), toSign))
+
+	return s.endpoint.Scheme + "://" + host + uriEncode(path, false) + "?" + canonicalQuery(q) + "&X-Amz-Signature=" + sig, nil
+}
+
+// signingKey derives the SigV4 key for one day, region and service.
+func signingKey(secret, date, region, service string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{date, region, service, "aws4_request"} { key = hmacSHA256(key, part) }
+	return key
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q { keys = append(keys, k) }
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs { parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true)) }
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode is SigV4's percent-encoding: everything but unreserved
+// characters, and slashes unless encodeSlash.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,165 @@
+// Package counters keeps hot counter fields (likeCount, commentCount,
+// followersCount, …) off the per-document write limit.
+//
+// A document's counters start inline: increments go straight to its
+// fields. Once a service instance sees a document take more than
+// COUNTER_PROMOTE_WRITES counter writes in a minute it promotes it to
+// sharded mode: the document gets counterShards: n (COUNTER_SHARDS, 10 by
+// default) and later increments land on a random one of
+// {doc}/counterShards/{0..n-1} instead. RollUp, run periodically, folds the
+// shards back into the document's fields, so readers keep reading plain
+// fields that lag at most one roll-up behind. A shard only exists between
+// an increment and the next roll-up, which deletes it, so RollUp finds the
+// pending work with one collection group query and its cost follows the
+// shards written since the last run, not the number of sharded documents.
+package counters
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// DefaultShards is the shard count documents are promoted to.
+	DefaultShards = envInt("COUNTER_SHARDS", 10)
+	// PromoteAfter is the counter writes per minute, as seen by one
+	// instance, that promote a document.
+	PromoteAfter = envInt("COUNTER_PROMOTE_WRITES", 30)
+)
+
+// ShardsField on a document holds its shard count; absent means inline.
+const ShardsField = "counterShards"
+
+// Shards is the shard count of doc, 0 while its counters are inline.
+func Shards(doc *firestore.DocumentSnapshot) int {
+	if !doc.Exists() { return 0 }
+	n, _ := doc.Data()[ShardsField].(int64)
+	return int(n)
+}
+
+// ShardsOf reads the shard count of ref, for writers that have not read
+// the document anyway. A missing document counts as inline.
+func ShardsOf(ctx context.Context, ref *firestore.DocumentRef) int {
+	doc, err := ref.Get(ctx)
+	if err != nil { return 0 }
+	return Shards(doc)
+}
+
+func shardRef(ref *firestore.DocumentRef, i int) *firestore.DocumentRef {
+	return ref.Collection("counterShards").Doc(strconv.Itoa(i))
+}
+
+// Add adds delta to field of ref inside tx; shards is the document's shard
+// count as read in the same transaction.
+func Add(tx *firestore.Transaction, ref *firestore.DocumentRef, shards int, field string, delta int64) error {
+	if shards <= 0 { return tx.Update(ref, []firestore.Update{{Path: field, Value: firestore.Increment(delta)}}) }
+	return tx.Set(shardRef(ref, rand.IntN(shards)), map[string]any{field: firestore.Increment(delta)}, firestore.MergeAll)
+}
+
+// AddBatch is Add for write batches.
+func AddBatch(b *firestore.WriteBatch, ref *firestore.DocumentRef, shards int, field string, delta int64) {
+	if shards <= 0 {
+		b.Update(ref, []firestore.Update{{Path: field, Value: firestore.Increment(delta)}})
+		return
+	}
+	b.Set(shardRef(ref, rand.IntN(shards)), map[string]any{field: firestore.Increment(delta)}, firestore.MergeAll)
+}
+
+/* ────── promotion ────────────────────────────────────────────────────────── */
+
+// Counter writes are tallied per document in fixed one-minute windows. The
+// tally is per instance, which undercounts when traffic is spread out, but
+// every instance promotes on its own once its share runs hot.
+var (
+	mu     sync.Mutex
+	window int64
+	writes = map[string]int{}
+)
+
+// Seen records a committed counter write to ref, whose shard count was
+// shards, and promotes the document once it runs hot. Failures are only
+// logged: the write itself already succeeded.
+func Seen(ctx context.Context, fs *firestore.Client, ref *firestore.DocumentRef, shards int) {
+	if shards > 0 || PromoteAfter <= 0 { return }
+	mu.Lock()
+	if now := time.Now().Unix() / 60; now != window { window, writes = now, map[string]int{} }
+	writes[ref.Path]++
+	hot := writes[ref.Path] == PromoteAfter
+	mu.Unlock()
+	if !hot { return }
+
+	if err := Promote(ctx, fs, ref, DefaultShards); err != nil { log.Printf("counters: promote %s: %v", ref.Path, err) }
+}
+
+// Promote switches ref to n shards. Already sharded documents keep their
+// count, since shards in use must not be orphaned.
+func Promote(ctx context.Context, fs *firestore.Client, ref *firestore.DocumentRef, n int) error {
+	if n <= 0 { return fmt.Errorf("counters: bad shard count %d", n) }
+	return fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		if Shards(doc) > 0 { return nil }
+		return tx.Update(ref, []firestore.Update{{Path: ShardsField, Value: n}})
+	})
+}
+
+/* ────── roll-up ──────────────────────────────────────────────────────────── */
+
+// RollUp folds every pending shard into its document's fields and returns
+// how many documents it updated. Each shard is folded in its own
+// transaction so that a busy document only ever conflicts on one shard.
+// Shards of deleted documents are dropped.
+func RollUp(ctx context.Context, fs *firestore.Client) (int, error) {
+	shards, err := fs.CollectionGroup("counterShards").Documents(ctx).GetAll()
+	if err != nil { return 0, err }
+
+	touched := map[string]bool{}
+	for _, s := range shards {
+		doc := s.Ref.Parent.Parent
+		if doc == nil { continue }
+		if err := rollUpShard(ctx, fs, doc, s.Ref); err != nil {
+			log.Printf("counters: roll up %s: %v", s.Ref.Path, err)
+			continue
+		}
+		touched[doc.Path] = true
+	}
+	return len(touched), nil
+}
+
+func rollUpShard(ctx context.Context, fs *firestore.Client, doc, shard *firestore.DocumentRef) error {
+	return fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		sdoc, err := tx.Get(shard)
+		if status.Code(err) == codes.NotFound { return nil } // folded by a concurrent run
+		if err != nil { return err }
+		if _, err := tx.Get(doc); status.Code(err) == codes.NotFound {
+			return tx.Delete(shard)
+		} else if err != nil {
+			return err
+		}
+
+		var ups []firestore.Update
+		for field, v := range sdoc.Data() {
+			if delta, ok := v.(int64); ok && delta != 0 { ups = append(ups, firestore.Update{Path: field, Value: firestore.Increment(delta)}) }
+		}
+		if len(ups) > 0 {
+			if err := tx.Update(doc, ups); err != nil { return err }
+		}
+		return tx.Delete(shard)
+	})
+}
+
+func envInt(k string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(k))
+	if err != nil { return def }
+	return n
+}
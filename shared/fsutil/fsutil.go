@@ -0,0 +1,66 @@
+// Package fsutil holds the Firestore helpers every service needs: deleting
+// a document's subcollections and answering for a failed transaction.
+package fsutil
+
+import (
+	"context"
+	"net/http"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DeleteSubcollections recursively deletes every collection below ref.
+// Firestore leaves them behind when a document is deleted.
+func DeleteSubcollections(c context.Context, fs *firestore.Client, ref *firestore.DocumentRef) error {
+	cols := ref.Collections(c)
+	for {
+		col, err := cols.Next()
+		if err == iterator.Done { return nil }
+		if err != nil { return err }
+		if err := DeleteCollection(c, fs, col); err != nil { return err }
+	}
+}
+
+// DeleteCollection deletes every document of col and, recursively, their
+// subcollections.
+func DeleteCollection(c context.Context, fs *firestore.Client, col *firestore.CollectionRef) error {
+	for {
+		docs, err := col.Limit(400).Documents(c).GetAll()
+		if err != nil { return err }
+		if len(docs) == 0 { return nil }
+
+		b := fs.Batch()
+		for _, d := range docs {
+			if err := DeleteSubcollections(c, fs, d.Ref); err != nil { return err }
+			b.Delete(d.Ref)
+		}
+		if _, err := b.Commit(c); err != nil { return err }
+	}
+}
+
+// WriteTxErr maps the status codes transactions fail with to HTTP errors
+// and reports whether err was nil, so handlers can write
+//
+//	if !fsutil.WriteTxErr(w, err) { return }
+//
+// InvalidArgument and FailedPrecondition carry a message for the client.
+func WriteTxErr(w http.ResponseWriter, err error) bool {
+	switch status.Code(err) {
+	case codes.OK:
+		return true
+	case codes.NotFound:
+		http.Error(w, "not found", http.StatusNotFound)
+	case codes.PermissionDenied:
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case codes.InvalidArgument:
+		http.Error(w, status.Convert(err).Message(), http.StatusBadRequest)
+	case codes.FailedPrecondition:
+		http.Error(w, status.Convert(err).Message(), http.StatusConflict)
+	default:
+		http.Error(w, "db write err", http.StatusInternalServerError)
+	}
+	return false
+}
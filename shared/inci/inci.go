@@ -0,0 +1,211 @@
+// Package inci parses INCI ingredient lists and flags allergens, irritants
+// and comedogenic ingredients from the dataset in ingredients.json.
+//
+// The dataset is maintained by hand: add an entry (canonical INCI name,
+// common synonyms, flags and a 0–5 comedogenic rating) and redeploy the
+// services that embed this package.
+package inci
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Flags an ingredient may carry.
+const (
+	FlagFragrance   = "fragrance"
+	FlagAllergen    = "allergen"
+	FlagIrritant    = "irritant"
+	FlagComedogenic = "comedogenic"
+)
+
+// ComedogenicThreshold is the rating from which an ingredient is flagged
+// comedogenic.
+const ComedogenicThreshold = 3
+
+// Ingredient is one analysed entry of a list.
+type Ingredient struct {
+	Name        string   `json:"name"`
+	Known       bool     `json:"known"` // present in the dataset
+	Flags       []string `json:"flags"`
+	Comedogenic int      `json:"comedogenic,omitempty"`
+}
+
+// Analysis summarises a whole ingredient list.
+type Analysis struct {
+	Ingredients []Ingredient `json:"ingredients"`
+	Flags       []string     `json:"flags"` // union over all ingredients
+}
+
+type entry struct {
+	Name        string   `json:"name"`
+	Synonyms    []string `json:"synonyms"`
+	Flags       []string `json:"flags"`
+	Comedogenic int      `json:"comedogenic"`
+}
+
+//go:embed ingredients.json
+var dataset []byte
+
+// byKey maps the Key of every name and synonym to its dataset entry.
+var byKey = map[string]*entry{}
+
+func init() {
+	var entries []*entry
+	if err := json.Unmarshal(dataset, &entries); err != nil { panic("inci: bad dataset: " + err.Error()) }
+	for _, e := range entries {
+		if e.Comedogenic >= ComedogenicThreshold { e.Flags = append(e.Flags, FlagComedogenic) }
+		byKey[Key(e.Name)] = e
+		for _, s := range e.Synonyms { byKey[Key(s)] = e }
+	}
+}
+
+var spaces = regexp.MustCompile(`\s+`)
+
+// Key is the comparison form of an ingredient name: lower case, single
+// spaced, plain hyphens.
+func Key(name string) string {
+	name = strings.NewReplacer("‐", "-", "‑", "-", "–", "-", "—", "-").Replace(name)
+	return strings.ToLower(spaces.ReplaceAllString(strings.TrimSpace(name), " "))
+}
+
+// Normalize returns the canonical INCI name of a single ingredient. Entries
+// like "Aqua (Water)" or "Aqua/Water/Eau" resolve through any of their
+// parts; unknown names come back cleaned but otherwise unchanged.
+func Normalize(name string) string {
+	name = clean(name)
+	if e := lookup(name); e != nil { return e.Name }
+	return name
+}
+
+func lookup(name string) *entry {
+	if e := byKey[Key(name)]; e != nil { return e }
+	if open := strings.Index(name, "("); open > 0 && strings.HasSuffix(name, ")") {
+		if e := lookup(name[:open]); e != nil { return e }
+		if e := lookup(name[open+1 : len(name)-1]); e != nil { return e }
+	}
+	if strings.Contains(name, "/") {
+		for _, part := range strings.Split(name, "/") {
+			if e := byKey[Key(part)]; e != nil { return e }
+		}
+	}
+	return nil
+}
+
+// clean strips footnote markers ("Rosa Damascena Oil*", "°") and trailing
+// full stops that are not part of the name.
+func clean(name string) string {
+	name = strings.TrimSpace(spaces.ReplaceAllString(name, " "))
+	name = strings.Trim(name, "*°†‡¹²³ ")
+	if strings.HasSuffix(name, ".") && byKey[Key(name)] == nil { name = strings.TrimRight(name, ". ") }
+	return name
+}
+
+var (
+	label      = regexp.MustCompile(`(?i)^\s*(ingredients|ingrédients|inci)\s*:`)
+	mayContain = regexp.MustCompile(`(?i)\[?\(?\s*(\+/-|±|may contain|peut contenir)\s*:?`)
+	legend     = regexp.MustCompile(`(?:^|\.\s*)[*°†‡]+\s*\p{Ll}`) // "*organic farming", also after "Oil*."
+)
+
+// Parse splits a raw INCI string into canonical, de-duplicated ingredient
+// names in label order. Commas inside parentheses do not split, and the
+// "may contain (+/-)" colourant section is kept since those ingredients can
+// be present too.
+func Parse(raw string) []string {
+	raw = label.ReplaceAllString(raw, "")
+	raw = mayContain.ReplaceAllString(raw, ",")
+
+	var (
+		out   []string
+		seen  = map[string]bool{}
+		depth int
+		start int
+	)
+	emit := func(s string) {
+		if loc := legend.FindStringIndex(s); loc != nil { s = s[:loc[0]] } // cut a trailing "*organic farming" legend
+		s = strings.TrimSpace(s)
+		if s = Normalize(s); s == "" { return }
+		if k := Key(s); !seen[k] {
+			seen[k] = true
+			out = append(out, s)
+		}
+	}
+	for i, c := range raw {
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			// unbalanced closer left over from the "may contain" bracket
+			emit(raw[start:i])
+			start = i + 1
+		case ',', ';', '•', '\n':
+			if depth == 0 {
+				emit(raw[start:i])
+				start = i + len(string(c))
+			}
+		}
+	}
+	emit(raw[start:])
+	if out == nil { out = []string{} }
+	return out
+}
+
+// Analyze looks every ingredient up in the dataset.
+func Analyze(ingredients []string) Analysis {
+	a := Analysis{Ingredients: make([]Ingredient, 0, len(ingredients)), Flags: []string{}}
+	seen := map[string]bool{}
+	for _, name := range ingredients {
+		in := Ingredient{Name: name, Flags: []string{}}
+		if e := lookup(name); e != nil {
+			in.Name, in.Known, in.Comedogenic = e.Name, true, e.Comedogenic
+			in.Flags = append(in.Flags, e.Flags...)
+		}
+		for _, f := range in.Flags {
+			if !seen[f] {
+				seen[f] = true
+				a.Flags = append(a.Flags, f)
+			}
+		}
+		a.Ingredients = append(a.Ingredients, in)
+	}
+	return a
+}
+
+// IsFlag reports whether s names a flag rather than an ingredient; avoid
+// lists may contain either.
+func IsFlag(s string) bool {
+	switch Key(s) {
+	case FlagFragrance, FlagAllergen, FlagIrritant, FlagComedogenic:
+		return true
+	}
+	return false
+}
+
+// Matches returns the ingredients that hit an avoid list. Entries are
+// ingredient names (compared canonically) or whole flags such as
+// "fragrance".
+func Matches(ingredients, avoid []string) []string {
+	if len(avoid) == 0 { return nil }
+	names, flags := map[string]bool{}, map[string]bool{}
+	for _, a := range avoid {
+		if IsFlag(a) {
+			flags[Key(a)] = true
+		} else {
+			names[Key(Normalize(a))] = true
+		}
+	}
+
+	var out []string
+	for _, in := range Analyze(ingredients).Ingredients {
+		hit := names[Key(in.Name)]
+		for _, f := range in.Flags { hit = hit || flags[f] }
+		if hit { out = append(out, in.Name) }
+	}
+	return out
+}
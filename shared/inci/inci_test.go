@@ -0,0 +1,51 @@
+package inci
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"empty", "", []string{}},
+		{"label and synonyms", "Ingredients: Water, Glycerine, Fragrance", []string{"Aqua", "Glycerin", "Parfum"}},
+		{"French label", "Ingrédients : Eau, Glycérine", []string{"Aqua", "Glycérine"}},
+		{"slash and parenthesised synonyms", "Aqua/Water/Eau, Parfum (Fragrance), Tocopherol (Vitamin E)", []string{"Aqua", "Parfum", "Tocopherol"}},
+		{"repeats dropped, first kept", "Aqua, Glycerin, Water, glycerin", []string{"Aqua", "Glycerin"}},
+		{"commas inside parentheses", "Aqua, Sodium Laureth Sulfate (SLES, 70%), Limonene", []string{"Aqua", "Sodium Laureth Sulfate", "Limonene"}},
+		{"other separators", "Aqua; Glycerin • Limonene\nLinalool", []string{"Aqua", "Glycerin", "Limonene", "Linalool"}},
+		{"footnote markers", "Aqua, Rosa Damascena Flower Oil*, Citral°", []string{"Aqua", "Rosa Damascena Flower Oil", "Citral"}},
+		{"legend after the list", "Aqua, Cocos Nucifera Oil*. *organic farming", []string{"Aqua", "Cocos Nucifera Oil"}},
+		{"trailing full stop", "Aqua, Glycerin, Limonene.", []string{"Aqua", "Glycerin", "Limonene"}},
+		{"full stop belonging to the name", "Aqua, Alcohol Denat.", []string{"Aqua", "Alcohol Denat."}},
+		{"may contain section", "Aqua, Glycerin [+/- CI 77891, CI 77491]", []string{"Aqua", "Glycerin", "CI 77891", "CI 77491"}},
+		{"may contain in words", "Aqua. May contain: Mica, CI 77491", []string{"Aqua", "Mica", "CI 77491"}},
+		{"whitespace and hyphens", "  Alpha–Isomethyl   Ionone ,Methyl 2‑Octynoate", []string{"Alpha-Isomethyl Ionone", "Methyl 2-Octynoate"}},
+		{"unknown kept as written", "Aqua, Snail Secretion Filtrate", []string{"Aqua", "Snail Secretion Filtrate"}},
+		{"empty entries", "Aqua,, ,Glycerin,", []string{"Aqua", "Glycerin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.raw); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	a := Analyze([]string{"Aqua", "Limonene", "Cocos Nucifera Oil", "Snail Secretion Filtrate"})
+	if want := []string{FlagFragrance, FlagAllergen, FlagComedogenic}; !reflect.DeepEqual(a.Flags, want) {
+		t.Errorf("Flags = %v, want %v", a.Flags, want)
+	}
+	if in := a.Ingredients[3]; in.Known || len(in.Flags) != 0 {
+		t.Errorf("unknown ingredient analysed as %+v", in)
+	}
+	if in := a.Ingredients[2]; !in.Known || in.Comedogenic < ComedogenicThreshold {
+		t.Errorf("Cocos Nucifera Oil analysed as %+v", in)
+	}
+}
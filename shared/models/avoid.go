@@ -0,0 +1,58 @@
+package models
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/inci"
+)
+
+// MaxAvoidIngredients caps a user's ingredient avoid list.
+const MaxAvoidIngredients = 200
+
+// The avoid list lives at users/{uid}/private/ingredients rather than on the
+// profile doc, which is served publicly.
+
+// AvoidListRef is the avoid-list document of uid.
+func AvoidListRef(fs *firestore.Client, uid string) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("private").Doc("ingredients")
+}
+
+// AvoidList holds canonical ingredient names and/or inci flags.
+type AvoidList struct {
+	Avoid []string `firestore:"avoid" json:"avoid"`
+}
+
+// LoadAvoidList returns uid's avoid list; nil for anonymous callers or
+// users who never saved one.
+func LoadAvoidList(ctx context.Context, fs *firestore.Client, uid string) []string {
+	if uid == "" { return nil }
+	doc, err := AvoidListRef(fs, uid).Get(ctx)
+	if err != nil { return nil }
+	var l AvoidList
+	if err := doc.DataTo(&l); err != nil { return nil }
+	return l.Avoid
+}
+
+// MarkAvoided warns uid about tagged products containing ingredients on
+// their avoid list. Ingredients are not serialised, so products are re-read
+// here rather than trusted from cached responses.
+func MarkAvoided(ctx context.Context, fs *firestore.Client, uid string, posts []PostResponse) {
+	var ids []string
+	for _, p := range posts {
+		for _, t := range p.ProductTags { ids = append(ids, t.ProductID) }
+	}
+	if len(ids) == 0 { return }
+	avoid := LoadAvoidList(ctx, fs, uid)
+	if len(avoid) == 0 { return }
+	products, err := LoadProducts(ctx, fs, ids)
+	if err != nil { return }
+
+	for i := range posts {
+		for j := range posts[i].ProductTags {
+			t := &posts[i].ProductTags[j]
+			t.AvoidMatches = inci.Matches(products[t.ProductID].Ingredients, avoid)
+		}
+	}
+}
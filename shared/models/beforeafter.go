@@ -0,0 +1,87 @@
+package models
+
+// Before/after posts show a transformation: exactly two photos, item 0
+// taken before and item 1 after, plus optional notes on the steps in
+// between. Processing crops both photos to one aspect ratio so clients can
+// stack them behind a slider.
+
+// Post kinds. Posts without a kind are standard single or carousel posts.
+const (
+	KindStandard    = "standard"
+	KindBeforeAfter = "before_after"
+)
+
+// Indexes of the two photos of a before/after post.
+const (
+	BeforeIndex = 0
+	AfterIndex  = 1
+)
+
+// Limits on the steps of a before/after post.
+const (
+	MaxSteps        = 10
+	MaxStepNoteLen  = 500 // characters
+	MaxStepProducts = 5
+)
+
+// BeforeAfter is the transformation part of a before/after post.
+type BeforeAfter struct {
+	Steps []Step `firestore:"steps,omitempty"`
+	// AspectRatio (width/height) both photos are cropped to; set by
+	// processing once both are uploaded.
+	AspectRatio float64 `firestore:"aspectRatio,omitempty"`
+}
+
+// Step is one step of a transformation: what was done and with what.
+type Step struct {
+	Note       string   `firestore:"note"                 json:"note"`
+	ProductIDs []string `firestore:"productIDs,omitempty" json:"productIDs,omitempty"`
+}
+
+// BeforeAfterResponse is the structured pair clients render as a slider.
+type BeforeAfterResponse struct {
+	Before      MediaResponse  `json:"before"`
+	After       MediaResponse  `json:"after"`
+	AspectRatio float64        `json:"aspectRatio,omitempty"` // 0 until both photos are processed
+	Steps       []StepResponse `json:"steps"`
+}
+
+// StepResponse is a step with its products resolved; see FillProductTags.
+type StepResponse struct {
+	Note     string           `json:"note"`
+	Products []ProductSummary `json:"products"`
+
+	productIDs []string
+}
+
+// IsBeforeAfter reports whether p is a before/after post.
+func (p Post) IsBeforeAfter() bool { return p.Kind == KindBeforeAfter }
+
+func beforeAfterResponse(p Post, media []MediaResponse) *BeforeAfterResponse {
+	if !p.IsBeforeAfter() || len(media) <= AfterIndex { return nil }
+	resp := &BeforeAfterResponse{Before: media[BeforeIndex], After: media[AfterIndex], Steps: []StepResponse{}}
+	if p.BeforeAfter == nil { return resp }
+	resp.AspectRatio = p.BeforeAfter.AspectRatio
+	for _, s := range p.BeforeAfter.Steps {
+		resp.Steps = append(resp.Steps, StepResponse{Note: s.Note, Products: []ProductSummary{}, productIDs: s.ProductIDs})
+	}
+	return resp
+}
+
+// TaggedProductIDs is ProductIDs extended by the products of the steps of
+// ba (nil for other posts); it is what "productIDs" holds, so a product's
+// post listing includes transformations that name it in a step.
+func TaggedProductIDs(tags []ProductTag, ba *BeforeAfter) []string {
+	out := ProductIDs(tags)
+	if ba == nil { return out }
+	seen := map[string]bool{}
+	for _, id := range out { seen[id] = true }
+	for _, s := range ba.Steps {
+		for _, id := range s.ProductIDs {
+			if seen[id] { continue }
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
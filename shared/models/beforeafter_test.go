@@ -0,0 +1,38 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTaggedProductIDs(t *testing.T) {
+	tags := func(ids ...string) []ProductTag {
+		var out []ProductTag
+		for i, id := range ids { out = append(out, ProductTag{ProductID: id, MediaIndex: i % 2}) }
+		return out
+	}
+	tests := []struct {
+		name string
+		tags []ProductTag
+		ba   *BeforeAfter
+		want []string
+	}{
+		{"nothing", nil, nil, []string{}},
+		{"tags only, repeats dropped", tags("a", "b", "a"), nil, []string{"a", "b"}},
+		{"steps without tags", nil, &BeforeAfter{Steps: []Step{{Note: "cleanse", ProductIDs: []string{"b", "c"}}}}, []string{"b", "c"}},
+		{
+			"tags first, then new step products in order",
+			tags("a", "b"),
+			&BeforeAfter{Steps: []Step{{ProductIDs: []string{"b", "c"}}, {Note: "rest"}, {ProductIDs: []string{"d", "c", "a"}}}},
+			[]string{"a", "b", "c", "d"},
+		},
+		{"empty steps", tags("a"), &BeforeAfter{}, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaggedProductIDs(tt.tags, tt.ba); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TaggedProductIDs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Comment mirrors posts/{postID}/comments/{id}. Replies are one level deep:
+// a reply's ParentID names a top-level comment, top-level comments have none.
+type Comment struct {
+	ID         string     `firestore:"id"`
+	PostID     string     `firestore:"postID"`
+	AuthorID   string     `firestore:"authorID"`
+	Text       string     `firestore:"text"`
+	Entities   []Entity   `firestore:"entities,omitempty"` // mentions and hashtags in Text
+	ParentID   string     `firestore:"parentID"`
+	LikeCount  int64      `firestore:"likeCount"`
+	ReplyCount int64      `firestore:"replyCount"`
+	Timestamp  time.Time  `firestore:"timestamp"`
+	Edited     bool       `firestore:"edited,omitempty"`
+	EditedAt   time.Time  `firestore:"editedAt,omitempty"`
+	Moderation Moderation `firestore:"moderation"`
+}
+
+// CommentResponse is the public JSON shape of a comment.
+type CommentResponse struct {
+	ID         string        `json:"id"`
+	PostID     string        `json:"postID"`
+	Author     AuthorSummary `json:"author"`
+	Text       string        `json:"text"`
+	Entities   []Entity      `json:"entities"`
+	ParentID   string        `json:"parentID,omitempty"`
+	LikeCount  int64         `json:"likeCount"`
+	LikedByMe  bool          `json:"likedByMe"`
+	ReplyCount int64         `json:"replyCount"`
+	Timestamp  string        `json:"timestamp"`
+	Edited     bool          `json:"edited"`
+	EditedAt   string        `json:"editedAt,omitempty"`
+	Status     string        `json:"status,omitempty"` // "blocked" when moderation hid it; only its author sees it
+}
+
+// CommentFromDoc decodes a Firestore snapshot; ID falls back to the doc ID.
+func CommentFromDoc(doc *firestore.DocumentSnapshot) (Comment, error) {
+	var c Comment
+	if err := doc.DataTo(&c); err != nil { return c, err }
+	if c.ID == "" { c.ID = doc.Ref.ID }
+	return c, nil
+}
+
+// CommentRef is posts/{postID}/comments/{commentID}.
+func CommentRef(fs *firestore.Client, postID, commentID string) *firestore.DocumentRef {
+	return fs.Collection("posts").Doc(postID).Collection("comments").Doc(commentID)
+}
+
+// CommentLikeRef is the like document of uid on a comment.
+func CommentLikeRef(fs *firestore.Client, postID, commentID, uid string) *firestore.DocumentRef {
+	return CommentRef(fs, postID, commentID).Collection("likes").Doc(uid)
+}
+
+// CommentResponses shapes comments, resolving authors and the caller's likes.
+func CommentResponses(ctx context.Context, fs *firestore.Client, uid string, comments []Comment) []CommentResponse {
+	uids := make([]string, 0, len(comments))
+	for _, c := range comments { uids = append(uids, c.AuthorID) }
+	authors, _ := LoadAuthors(ctx, fs, uids)
+
+	out := make([]CommentResponse, 0, len(comments))
+	for _, c := range comments {
+		a := authors[c.AuthorID]
+		a.ID = c.AuthorID
+		out = append(out, CommentResponse{
+			ID:         c.ID,
+			PostID:     c.PostID,
+			Author:     a,
+			Text:       c.Text,
+			Entities:   nonNilEntities(c.Entities),
+			ParentID:   c.ParentID,
+			LikeCount:  c.LikeCount,
+			ReplyCount: c.ReplyCount,
+			Timestamp:  FormatTime(c.Timestamp),
+			Edited:     c.Edited,
+			EditedAt:   FormatTime(c.EditedAt),
+			Status:     blockedStatus(c.Moderation),
+		})
+	}
+
+	if uid != "" && len(out) > 0 {
+		refs := make([]*firestore.DocumentRef, 0, len(out))
+		for _, c := range out { refs = append(refs, CommentLikeRef(fs, c.PostID, c.ID, uid)) }
+		if docs, err := fs.GetAll(ctx, refs); err == nil {
+			for i, d := range docs { out[i].LikedByMe = d.Exists() }
+		}
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Duplicate names the earlier post by another author whose media a post's
+// media item near-matches (see moderation.FindOriginal). Only moderators
+// see it.
+type Duplicate struct {
+	PostID     string    `firestore:"postID"     json:"postID"`
+	AuthorID   string    `firestore:"authorID"   json:"authorID"`
+	MediaIndex int       `firestore:"mediaIndex" json:"mediaIndex"` // the matching item of this post
+	Distance   int       `firestore:"distance"   json:"distance"`   // differing hash bits, 0 = identical
+	DetectedAt time.Time `firestore:"detectedAt" json:"detectedAt"`
+}
+
+// Credit attributes a post to the creator it was reposted from.
+type Credit struct {
+	PostID   string `firestore:"postID"`
+	AuthorID string `firestore:"authorID"`
+	Auto     bool   `firestore:"auto,omitempty"` // set by duplicate detection
+}
+
+// CreditResponse is the public JSON shape of a Credit.
+type CreditResponse struct {
+	PostID string        `json:"postID"`
+	Author AuthorSummary `json:"author"`
+}
+
+func creditResponse(c *Credit, authors map[string]AuthorSummary) *CreditResponse {
+	if c == nil { return nil }
+	a := authors[c.AuthorID]
+	a.ID = c.AuthorID
+	return &CreditResponse{PostID: c.PostID, Author: a}
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Entities are the @mentions and #hashtags found in captions and comments,
+// stored next to the text so clients can render links without re-parsing.
+// Start and End are offsets into the text in Unicode code points (runes),
+// End exclusive, and cover the leading "@" or "#". They are not UTF-16
+// offsets: clients indexing strings by UTF-16 unit (JavaScript, Java,
+// NSString) must convert, as every character outside the BMP, emoji
+// included, counts once here and twice there.
+type Entity struct {
+	Type   string `firestore:"type"             json:"type"` // EntityMention | EntityHashtag
+	Start  int    `firestore:"start"            json:"start"`
+	End    int    `firestore:"end"              json:"end"`
+	Text   string `firestore:"text"             json:"text"`             // username or tag, without the prefix
+	UserID string `firestore:"userID,omitempty" json:"userID,omitempty"` // mentions only
+}
+
+const (
+	EntityMention = "mention"
+	EntityHashtag = "hashtag"
+)
+
+// UsernameLowerField on user documents holds the username lower-cased;
+// usernames are unique case-insensitively and mentions match on it.
+const UsernameLowerField = "usernameLower"
+
+// MaxMentions caps how many users one caption or comment can mention;
+// further mentions stay plain text.
+const MaxMentions = 20
+
+// Mention policies, stored as mentionPolicy on the user document. Unset
+// means everyone.
+const (
+	MentionEveryone  = "everyone"
+	MentionFollowing = "following" // only people the user follows
+	MentionNobody    = "nobody"
+)
+
+// MentionPolicies lists every accepted mentionPolicy value.
+var MentionPolicies = map[string]bool{MentionEveryone: true, MentionFollowing: true, MentionNobody: true}
+
+// A mention or hashtag must not be glued to a preceding word character, so
+// "me@example.com" and "a#b" are plain text.
+var (
+	mentionRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.@#])(@[A-Za-z0-9_.]{1,30})`)
+	hashtagRe = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@#&])(#[\p{L}\p{N}_]{1,100})`)
+)
+
+// ParseEntities finds mentions and hashtags in text, in order of
+// appearance. Mentions are unresolved; see ResolveMentions.
+func ParseEntities(text string) []Entity {
+	var out []Entity
+	add := func(typ string, m []int) {
+		start := utf8.RuneCountInString(text[:m[2]])
+		token := strings.TrimRight(text[m[2]:m[3]], ".") // "@anna." ends a sentence
+		out = append(out, Entity{Type: typ, Start: start, End: start + utf8.RuneCountInString(token), Text: token[1:]})
+	}
+	for _, m := range mentionRe.FindAllStringSubmatchIndex(text, -1) {
+		if m[3]-m[2] > 1 && strings.Trim(text[m[2]+1:m[3]], ".") != "" { add(EntityMention, m) }
+	}
+	for _, m := range hashtagRe.FindAllStringSubmatchIndex(text, -1) { add(EntityHashtag, m) }
+
+	// merge the two passes back into text order
+	for i := 1; i < len(out); i++ {
+		for j := i; j > 0 && out[j].Start < out[j-1].Start; j-- { out[j], out[j-1] = out[j-1], out[j] }
+	}
+	return out
+}
+
+// ResolveMentions maps mentioned usernames to user IDs on behalf of author
+// and drops mentions that name no user, exceed MaxMentions or that the
+// mentioned user's mentionPolicy does not allow from author. Those stay in
+// the text as plain words. Hashtags pass through unchanged.
+func ResolveMentions(ctx context.Context, fs *firestore.Client, author string, entities []Entity) []Entity {
+	var names, written []string // lower-cased, and as written for legacy profiles
+	seen, distinct := map[string]bool{}, map[string]bool{}
+	for _, e := range entities {
+		if e.Type != EntityMention || len(distinct) == MaxMentions { continue }
+		lower := strings.ToLower(e.Text)
+		if !distinct[lower] { names = append(names, lower) }
+		distinct[lower] = true
+		if !seen[e.Text] { written = append(written, e.Text) }
+		seen[e.Text] = true
+	}
+	if len(names) == 0 { return entities }
+
+	users := map[string]string{}    // lower-cased username → uid
+	policies := map[string]string{} // uid → policy
+	lookup := func(field string, names []string) {
+		for len(names) > 0 {
+			chunk := names[:min(len(names), 30)]
+			names = names[len(chunk):]
+			docs, err := fs.Collection("users").Where(field, "in", chunk).Documents(ctx).GetAll()
+			if err != nil { continue }
+			for _, d := range docs {
+				name, _ := d.Data()["username"].(string)
+				policy, _ := d.Data()["mentionPolicy"].(string)
+				users[strings.ToLower(name)] = d.Ref.ID
+				policies[d.Ref.ID] = policy
+			}
+		}
+	}
+	lookup(UsernameLowerField, names)
+
+	// profiles saved before usernameLower existed only match as written
+	var missing []string
+	for _, n := range written {
+		if users[strings.ToLower(n)] == "" { missing = append(missing, n) }
+	}
+	lookup("username", missing)
+
+	// "following" policies need the mentioned user to follow author
+	var refs []*firestore.DocumentRef
+	for uid, policy := range policies {
+		if policy == MentionFollowing && uid != author { refs = append(refs, FollowerRef(fs, author, uid)) }
+	}
+	follows := map[string]bool{}
+	if len(refs) > 0 {
+		if docs, err := fs.GetAll(ctx, refs); err == nil {
+			for _, d := range docs { follows[d.Ref.ID] = d.Exists() }
+		}
+	}
+
+	out := make([]Entity, 0, len(entities))
+	for _, e := range entities {
+		if e.Type == EntityMention {
+			uid := users[strings.ToLower(e.Text)]
+			if uid == "" || !distinct[strings.ToLower(e.Text)] { continue }
+			if uid != author {
+				switch policies[uid] {
+				case MentionNobody:
+					continue
+				case MentionFollowing:
+					if !follows[uid] { continue }
+				}
+			}
+			e.UserID = uid
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// TextEntities parses and resolves text written by author.
+func TextEntities(ctx context.Context, fs *firestore.Client, author, text string) []Entity {
+	return ResolveMentions(ctx, fs, author, ParseEntities(text))
+}
+
+// MentionedIDs lists the distinct users mentioned in entities.
+func MentionedIDs(entities []Entity) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, e := range entities {
+		if e.UserID == "" || seen[e.UserID] { continue }
+		seen[e.UserID] = true
+		out = append(out, e.UserID)
+	}
+	return out
+}
+
+// Hashtags lists the tags used in entities, without the "#".
+func Hashtags(entities []Entity) []string {
+	var out []string
+	for _, e := range entities {
+		if e.Type == EntityHashtag { out = append(out, e.Text) }
+	}
+	return out
+}
+
+func nonNilEntities(e []Entity) []Entity {
+	if e == nil { return []Entity{} }
+	return e
+}
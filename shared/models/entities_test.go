@@ -0,0 +1,40 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEntities(t *testing.T) {
+	mention := func(start, end int, text string) Entity { return Entity{Type: EntityMention, Start: start, End: end, Text: text} }
+	hashtag := func(start, end int, text string) Entity { return Entity{Type: EntityHashtag, Start: start, End: end, Text: text} }
+
+	tests := []struct {
+		name string
+		text string
+		want []Entity
+	}{
+		{"empty", "", nil},
+		{"plain text", "no entities here", nil},
+		{"mention at start", "@anna hi", []Entity{mention(0, 5, "anna")}},
+		{"hashtag at end", "new in #skincare", []Entity{hashtag(7, 16, "skincare")}},
+		{"email is not a mention", "write to me@example.com", nil},
+		{"glued hashtag is not one", "a#b and x&#39;", nil},
+		{"trailing full stop", "thanks @anna.", []Entity{mention(7, 12, "anna")}},
+		{"dots inside username", "by @anna.k.", []Entity{mention(3, 10, "anna.k")}},
+		{"only dots", "@... nothing", nil},
+		{"trailing punctuation", "(@anna), #glow!", []Entity{mention(1, 6, "anna"), hashtag(9, 14, "glow")}},
+		{"repeated mention", "@anna @anna @Anna", []Entity{mention(0, 5, "anna"), mention(6, 11, "anna"), mention(12, 17, "Anna")}},
+		{"non-ASCII hashtag", "#güzellik #化粧品", []Entity{hashtag(0, 9, "güzellik"), hashtag(10, 14, "化粧品")}},
+		{"rune offsets after emoji", "✨😍 @anna", []Entity{mention(3, 8, "anna")}},
+		{"mixed order", "#a @b #c", []Entity{hashtag(0, 2, "a"), mention(3, 5, "b"), hashtag(6, 8, "c")}},
+		{"mention after newline", "line\n@anna", []Entity{mention(5, 10, "anna")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseEntities(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseEntities(%q) = %+v, want %+v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Likes live at posts/{postID}/likes/{uid}; the doc ID makes a like
+// idempotent per user.
+
+// LikeRef is the like document of uid on postID.
+func LikeRef(fs *firestore.Client, postID, uid string) *firestore.DocumentRef {
+	return fs.Collection("posts").Doc(postID).Collection("likes").Doc(uid)
+}
+
+// MarkLikedByMe sets LikedByMe on every post uid has liked. Anonymous
+// callers (uid == "") are left untouched.
+func MarkLikedByMe(ctx context.Context, fs *firestore.Client, uid string, posts []PostResponse) {
+	if uid == "" || len(posts) == 0 { return }
+	refs := make([]*firestore.DocumentRef, 0, len(posts))
+	for _, p := range posts { refs = append(refs, LikeRef(fs, p.ID, uid)) }
+
+	docs, err := fs.GetAll(ctx, refs)
+	if err != nil { return }
+	for i, d := range docs { posts[i].LikedByMe = d.Exists() }
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MaxMediaItems caps the number of items in a carousel post.
+const MaxMediaItems = 10
+
+// MediaItem is one entry of a post's ordered media list.
+type MediaItem struct {
+	Path          string         `firestore:"path"`
+	Type          string         `firestore:"type"` // "image" | "video"
+	ThumbnailPath string         `firestore:"thumbnailPath,omitempty"`
+	Size          int64          `firestore:"size,omitempty"` // declared upload size in bytes; the upload may not exceed it
+	Processed     bool           `firestore:"processed"`
+	Width         int            `firestore:"width,omitempty"`
+	Height        int            `firestore:"height,omitempty"`
+	BlurHash      string         `firestore:"blurHash,omitempty"`
+	Variants      []ImageVariant `firestore:"variants,omitempty"`
+}
+
+// ImageVariant is a resized, metadata-free rendition of an image item.
+type ImageVariant struct {
+	Path   string `firestore:"path"   json:"-"`
+	Format string `firestore:"format" json:"format"` // "webp" | "jpeg"
+	Width  int    `firestore:"width"  json:"width"`
+	Height int    `firestore:"height" json:"height"`
+	URL    string `firestore:"-"      json:"url,omitempty"`
+}
+
+// MediaResponse is the public JSON shape of a MediaItem.
+type MediaResponse struct {
+	Type         string         `json:"type"`
+	URL          string         `json:"url,omitempty"`
+	ThumbnailURL string         `json:"thumbnailURL,omitempty"`
+	Processed    bool           `json:"processed"`
+	Width        int            `json:"width,omitempty"`
+	Height       int            `json:"height,omitempty"`
+	BlurHash     string         `json:"blurHash,omitempty"`
+	Variants     []ImageVariant `json:"variants,omitempty"`
+}
+
+// Items returns the post's media in display order. Posts written before
+// carousels existed only carry mediaPath/mediaType and yield one item.
+func (p Post) Items() []MediaItem {
+	if len(p.Media) > 0 { return p.Media }
+	if p.MediaPath == "" { return nil }
+	return []MediaItem{{Path: p.MediaPath, Type: p.MediaType, ThumbnailPath: p.ThumbnailPath, Processed: p.Processed}}
+}
+
+// AllProcessed reports whether every item has finished processing.
+func AllProcessed(items []MediaItem) bool {
+	for _, m := range items {
+		if !m.Processed { return false }
+	}
+	return true
+}
+
+// MediaObjectPath is the storage path of item idx of a post. Item 0 keeps
+// the pre-carousel layout so single-media posts are unchanged.
+func MediaObjectPath(authorID, postID string, idx int, ext string) string {
+	if idx == 0 { return fmt.Sprintf("posts/%s/%s.%s", authorID, postID, ext) }
+	return fmt.Sprintf("posts/%s/%s_%d.%s", authorID, postID, idx, ext)
+}
+
+// ParseMediaObjectPath is the inverse of MediaObjectPath. ok is false for
+// objects outside posts/ (e.g. derived thumbnails).
+func ParseMediaObjectPath(object string) (postID string, idx int, ok bool) {
+	parts := strings.Split(object, "/")
+	if len(parts) != 3 || parts[0] != "posts" { return "", 0, false }
+	name := strings.TrimSuffix(parts[2], filepath.Ext(parts[2]))
+	postID, suffix, found := strings.Cut(name, "_")
+	if !found { return postID, 0, postID != "" }
+	idx, err := strconv.Atoi(suffix)
+	if err != nil || idx <= 0 || idx >= MaxMediaItems { return "", 0, false }
+	return postID, idx, true
+}
+
+// DisplayPath is the object clients should load for the item: the largest
+// JPEG variant for images, the uploaded object for videos. Images have no
+// display path until processing has written their variants: the original
+// may still carry EXIF/GPS data, so it is never handed out.
+func (m MediaItem) DisplayPath() string {
+	if m.Type != "image" { return m.Path }
+	best := ImageVariant{}
+	for _, v := range m.Variants {
+		if v.Format == "jpeg" && v.Width > best.Width { best = v }
+	}
+	return best.Path
+}
+
+func mediaResponses(items []MediaItem) []MediaResponse {
+	out := make([]MediaResponse, 0, len(items))
+	for _, m := range items {
+		variants := make([]ImageVariant, 0, len(m.Variants))
+		for _, v := range m.Variants {
+			v.URL = readURL(v.Path)
+			variants = append(variants, v)
+		}
+		out = append(out, MediaResponse{
+			Type:         m.Type,
+			URL:          readURL(m.DisplayPath()),
+			ThumbnailURL: readURL(m.ThumbnailPath),
+			Processed:    m.Processed,
+			Width:        m.Width,
+			Height:       m.Height,
+			BlurHash:     m.BlurHash,
+			Variants:     variants,
+		})
+	}
+	return out
+}
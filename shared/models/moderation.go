@@ -0,0 +1,85 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// Moderation decisions, least to most severe. Content under review stays
+// visible; blocked content is hidden from everyone but its author.
+const (
+	ModerationAllow  = "allow"
+	ModerationReview = "review"
+	ModerationBlock  = "block"
+)
+
+var moderationRank = map[string]int{ModerationAllow: 0, ModerationReview: 1, ModerationBlock: 2}
+
+// ModerationCheck is one classifier verdict on one part of a post or
+// comment.
+type ModerationCheck struct {
+	Decision string   `firestore:"decision"`
+	Labels   []string `firestore:"labels,omitempty"`
+	Reason   string   `firestore:"reason,omitempty"`
+}
+
+// Moderation is the moderation state stored on posts and comments. Each
+// source ("caption", "text", "media:<i>") keeps its latest check; Decision
+// is the most severe of them until a moderator sets Override; from then on
+// it starts from the moderator's decision and checks can only raise it. Documents
+// without it were never checked and count as allowed.
+type Moderation struct {
+	Decision  string                     `firestore:"decision"`
+	Labels    []string                   `firestore:"labels,omitempty"`
+	Sources   map[string]ModerationCheck `firestore:"sources,omitempty"`
+	Override  string                     `firestore:"override,omitempty"` // moderator decision
+	CheckedAt time.Time                  `firestore:"checkedAt"`
+}
+
+// Blocked reports whether the content must be hidden.
+func (m Moderation) Blocked() bool { return m.Decision == ModerationBlock }
+
+// With records check for source and recomputes the overall decision. A
+// moderator override survives: once a moderator has decided, automated
+// checks can only make the decision more severe, never lift it, and the
+// queue sends the changed content back to them (see moderation.Enqueue).
+func (m Moderation) With(source string, check ModerationCheck) Moderation {
+	sources := make(map[string]ModerationCheck, len(m.Sources)+1)
+	for k, v := range m.Sources { sources[k] = v }
+	sources[source] = check
+
+	out := Moderation{Decision: ModerationAllow, Sources: sources, Override: m.Override, CheckedAt: time.Now().UTC()}
+	if m.Override != "" { out.Decision = MostSevere(m.Decision, m.Override, check.Decision) }
+	for _, c := range sources {
+		if m.Override == "" && MoreSevere(c.Decision, out.Decision) { out.Decision = c.Decision }
+		for _, l := range c.Labels {
+			if !slices.Contains(out.Labels, l) { out.Labels = append(out.Labels, l) }
+		}
+	}
+	sort.Strings(out.Labels)
+	return out
+}
+
+// Overridden is m with a moderator's decision taking precedence.
+func (m Moderation) Overridden(decision string) Moderation {
+	m.Override, m.Decision = decision, decision
+	return m
+}
+
+func blockedStatus(m Moderation) string {
+	if m.Blocked() { return StatusBlocked }
+	return ""
+}
+
+// MoreSevere reports whether decision a outranks b.
+func MoreSevere(a, b string) bool { return moderationRank[a] > moderationRank[b] }
+
+// MostSevere is the most severe of decisions; allow if there are none.
+func MostSevere(decisions ...string) string {
+	out := ModerationAllow
+	for _, d := range decisions {
+		if MoreSevere(d, out) { out = d }
+	}
+	return out
+}
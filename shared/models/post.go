@@ -0,0 +1,285 @@
+package models
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/signing"
+)
+
+// Post mirrors a document in the "posts" collection.
+// Internal fields (mediaPath, processed) never leave the backend – use
+// NewPostResponse to build what clients see.
+type Post struct {
+	ID            string       `firestore:"id"`
+	AuthorID      string       `firestore:"authorID"`
+	Caption       string       `firestore:"caption"`
+	Entities      []Entity     `firestore:"entities,omitempty"` // mentions and hashtags in Caption
+	Tags          []string     `firestore:"tags,omitempty"`
+	MediaPath     string       `firestore:"mediaPath"`
+	MediaType     string       `firestore:"mediaType"`
+	Media         []MediaItem  `firestore:"media,omitempty"`
+	ProductTags   []ProductTag `firestore:"productTags,omitempty"`
+	ProductIDs    []string     `firestore:"productIDs,omitempty"`
+	ThumbnailPath string       `firestore:"thumbnailPath,omitempty"`
+	LegacyThumb   string       `firestore:"thumbnailURL,omitempty"` // public URL written before signed reads, see PostFromDoc
+	LikeCount     int64        `firestore:"likeCount"`
+	CommentCount  int64        `firestore:"commentCount"`
+	SaveCount     int64        `firestore:"saveCount"`
+	Timestamp     time.Time    `firestore:"timestamp"`
+	Processed     bool         `firestore:"processed"`
+	Visibility    string       `firestore:"visibility,omitempty"`
+	Status        string       `firestore:"status,omitempty"`
+	Edited        bool         `firestore:"edited,omitempty"`
+	EditedAt      time.Time    `firestore:"editedAt,omitempty"`
+	Draft         bool         `firestore:"draft,omitempty"`     // stay a draft after finalize
+	PublishAt     time.Time    `firestore:"publishAt,omitempty"` // scheduled publish time
+	Moderation    Moderation   `firestore:"moderation"`
+	DuplicateOf   *Duplicate   `firestore:"duplicateOf,omitempty"` // moderators only
+	Credit        *Credit      `firestore:"credit,omitempty"`
+	Kind          string       `firestore:"kind,omitempty"` // "" (standard) or KindBeforeAfter
+	BeforeAfter   *BeforeAfter `firestore:"beforeAfter,omitempty"`
+}
+
+// Post lifecycle. A post is created pending upload and only becomes visible
+// once the client finalizes it and the uploaded media passes verification.
+// Finalized posts the author asked to hold back become drafts, or scheduled
+// when they carry a future PublishAt. Documents without a status predate
+// finalization and count as published.
+const (
+	StatusPendingUpload = "pending_upload"
+	StatusDraft         = "draft"
+	StatusScheduled     = "scheduled"
+	StatusPublished     = "published"
+	StatusRejected      = "rejected"
+
+	// StatusBlocked is never stored; responses show it to the author of a
+	// published post that moderation hid.
+	StatusBlocked = "blocked"
+)
+
+// IsPublished reports whether the post has gone out, blocked or not.
+func (p Post) IsPublished() bool {
+	return p.Status == "" || p.Status == StatusPublished
+}
+
+// IsLive reports whether the post may appear outside its author's own view:
+// published and not blocked by moderation.
+func (p Post) IsLive() bool {
+	return p.IsPublished() && !p.Moderation.Blocked()
+}
+
+// Visibility levels. Documents written before visibility existed carry no
+// field and count as public. See visibility.go for who may see what.
+const (
+	VisibilityPublic       = "public"
+	VisibilityFollowers    = "followers"
+	VisibilityCloseFriends = "close_friends"
+	VisibilityPrivate      = "private"
+)
+
+// Visibilities lists every accepted visibility value.
+var Visibilities = map[string]bool{
+	VisibilityPublic: true, VisibilityFollowers: true, VisibilityCloseFriends: true, VisibilityPrivate: true,
+}
+
+// IsPublic reports whether anyone, signed in or not, may see the post.
+func (p Post) IsPublic() bool {
+	return p.Visibility == "" || p.Visibility == VisibilityPublic
+}
+
+// AuthorSummary is the slice of a user profile embedded in post responses.
+type AuthorSummary struct {
+	ID        string `json:"id"        firestore:"-"`
+	Username  string `json:"username"  firestore:"username"`
+	AvatarURL string `json:"avatarURL" firestore:"avatarURL"`
+}
+
+// PostResponse is the public JSON shape of a post.
+type PostResponse struct {
+	ID           string               `json:"id"`
+	Author       AuthorSummary        `json:"author"`
+	Caption      string               `json:"caption"`
+	Entities     []Entity             `json:"entities"`
+	Tags         []string             `json:"tags"`
+	MediaType    string               `json:"mediaType"`
+	MediaURL     string               `json:"mediaURL,omitempty"`
+	ThumbnailURL string               `json:"thumbnailURL,omitempty"`
+	Media        []MediaResponse      `json:"media"`
+	ProductTags  []ProductTagResponse `json:"productTags"`
+	LikeCount    int64                `json:"likeCount"`
+	LikedByMe    bool                 `json:"likedByMe"`
+	CommentCount int64                `json:"commentCount"`
+	Timestamp    string               `json:"timestamp"`
+	Edited       bool                 `json:"edited"`
+	EditedAt     string               `json:"editedAt,omitempty"`
+	Visibility   string               `json:"visibility"`
+	SavedByMe    bool                 `json:"savedByMe"`
+	SaveCount    *int64               `json:"saveCount,omitempty"` // author only, see MarkSaved
+	Status       string               `json:"status,omitempty"`    // only set while not live
+	PublishAt    string               `json:"publishAt,omitempty"` // scheduled posts
+	Credit       *CreditResponse      `json:"credit,omitempty"`    // the creator this was reposted from
+	Kind         string               `json:"kind"`
+	BeforeAfter  *BeforeAfterResponse `json:"beforeAfter,omitempty"` // before/after posts only
+}
+
+// PostFromDoc decodes a Firestore snapshot; ID falls back to the doc ID.
+// Posts processed before thumbnails were signed carry a public thumbnailURL
+// instead of thumbnailPath; its object path is used in that case.
+func PostFromDoc(doc *firestore.DocumentSnapshot) (Post, error) {
+	var p Post
+	if err := doc.DataTo(&p); err != nil { return p, err }
+	if p.ID == "" { p.ID = doc.Ref.ID }
+	if p.ThumbnailPath == "" { p.ThumbnailPath = legacyThumbnailPath(p.LegacyThumb) }
+	return p, nil
+}
+
+// legacyThumbnailPath turns "https://storage.googleapis.com/<bucket>/<object>"
+// into "<object>"; anything else yields "".
+func legacyThumbnailPath(url string) string {
+	rest, ok := strings.CutPrefix(url, "https://storage.googleapis.com/")
+	if !ok { return "" }
+	_, object, _ := strings.Cut(rest, "/")
+	return object
+}
+
+// NewPostResponse shapes p for clients, embedding the author summary.
+// Product tags carry IDs only until FillProductTags resolves them.
+// MediaURL/ThumbnailURL describe the cover (first) item; Media lists all.
+func NewPostResponse(p Post, author AuthorSummary) PostResponse {
+	author.ID = p.AuthorID
+	media := mediaResponses(p.Items())
+	cover := MediaResponse{}
+	if len(media) > 0 { cover = media[0] }
+	tags := make([]ProductTagResponse, 0, len(p.ProductTags))
+	for _, t := range p.ProductTags { tags = append(tags, ProductTagResponse{ProductTag: t}) }
+	status := ""
+	if !p.IsPublished() { status = p.Status }
+	if p.IsPublished() && !p.IsLive() { status = StatusBlocked }
+	visibility := p.Visibility
+	if visibility == "" { visibility = VisibilityPublic }
+	kind := p.Kind
+	if kind == "" { kind = KindStandard }
+	return PostResponse{
+		ID:           p.ID,
+		Author:       author,
+		Caption:      p.Caption,
+		Entities:     nonNilEntities(p.Entities),
+		Tags:         nonNil(p.Tags),
+		MediaType:    p.MediaType,
+		MediaURL:     cover.URL,
+		ThumbnailURL: cover.ThumbnailURL,
+		Media:        media,
+		ProductTags:  tags,
+		LikeCount:    p.LikeCount,
+		CommentCount: p.CommentCount,
+		Timestamp:    FormatTime(p.Timestamp),
+		Edited:       p.Edited,
+		EditedAt:     FormatTime(p.EditedAt),
+		Visibility:   visibility,
+		Status:       status,
+		PublishAt:    FormatTime(p.PublishAt),
+		Credit:       creditResponse(p.Credit, nil),
+		Kind:         kind,
+		BeforeAfter:  beforeAfterResponse(p, media),
+	}
+}
+
+// MaxTags caps the number of tags on a post.
+const MaxTags = 30
+
+// NormalizeTags lower-cases tags, strips a leading '#', drops empties and
+// duplicates and keeps at most MaxTags in their original order.
+func NormalizeTags(tags []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(t), "#")))
+		if t == "" || seen[t] { continue }
+		seen[t] = true
+		out = append(out, t)
+		if len(out) == MaxTags { break }
+	}
+	return out
+}
+
+func nonNil(s []string) []string {
+	if s == nil { return []string{} }
+	return s
+}
+
+// readURL signs a GET URL for a private object; "" when there is nothing to
+// sign or signing is not configured in this service.
+func readURL(object string) string {
+	if object == "" || !signing.Enabled() { return "" }
+	url, err := signing.ReadURL(object)
+	if err != nil {
+		log.Printf("models: sign %s: %v", object, err)
+		return ""
+	}
+	return url
+}
+
+// FormatTime renders timestamps the same way in every response (RFC 3339, UTC).
+// Zero times – e.g. a ServerTimestamp not yet resolved – render as "".
+func FormatTime(t time.Time) string {
+	if t.IsZero() { return "" }
+	return t.UTC().Format(time.RFC3339)
+}
+
+// LoadAuthors fetches author summaries for the given user IDs in one round
+// trip. Unknown users map to a summary carrying only the ID.
+func LoadAuthors(ctx context.Context, fs *firestore.Client, uids []string) (map[string]AuthorSummary, error) {
+	out := map[string]AuthorSummary{}
+	var refs []*firestore.DocumentRef
+	for _, id := range uids {
+		if _, seen := out[id]; seen || id == "" { continue }
+		out[id] = AuthorSummary{ID: id}
+		refs = append(refs, fs.Collection("users").Doc(id))
+	}
+	if len(refs) == 0 { return out, nil }
+
+	docs, err := fs.GetAll(ctx, refs)
+	if err != nil { return out, err }
+	for _, d := range docs {
+		if !d.Exists() { continue }
+		var a AuthorSummary
+		if err := d.DataTo(&a); err != nil { continue }
+		a.ID = d.Ref.ID
+		out[a.ID] = a
+	}
+	return out, nil
+}
+
+// PostResponses shapes a batch of posts, resolving all authors (and
+// credited creators) at once.
+func PostResponses(ctx context.Context, fs *firestore.Client, posts []Post) []PostResponse {
+	uids := make([]string, 0, len(posts))
+	for _, p := range posts {
+		uids = append(uids, p.AuthorID)
+		if p.Credit != nil { uids = append(uids, p.Credit.AuthorID) }
+	}
+	authors, _ := LoadAuthors(ctx, fs, uids)
+
+	out := make([]PostResponse, 0, len(posts))
+	for _, p := range posts {
+		resp := NewPostResponse(p, authors[p.AuthorID])
+		resp.Credit = creditResponse(p.Credit, authors)
+		out = append(out, resp)
+	}
+	FillProductTags(ctx, fs, out)
+	return out
+}
+
+// Personalize fills in everything in posts that depends on the viewer uid:
+// likes, saves, creator-only counters and avoid-list warnings. It runs on
+// every response, cached or not.
+func Personalize(ctx context.Context, fs *firestore.Client, uid string, posts []PostResponse) {
+	MarkLikedByMe(ctx, fs, uid, posts)
+	MarkSaved(ctx, fs, uid, posts)
+	MarkAvoided(ctx, fs, uid, posts)
+}
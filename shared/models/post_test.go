@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestLegacyThumbnailPath(t *testing.T) {
+	tests := []struct {
+		name, url, want string
+	}{
+		{"empty", "", ""},
+		{"public GCS URL", "https://storage.googleapis.com/media-bkt/posts/u1/p1_thumb.jpg", "posts/u1/p1_thumb.jpg"},
+		{"bucket only", "https://storage.googleapis.com/media-bkt", ""},
+		{"other host", "https://cdn.example.com/posts/u1/p1_thumb.jpg", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := legacyThumbnailPath(tt.url); got != tt.want { t.Errorf("legacyThumbnailPath(%q) = %q, want %q", tt.url, got, tt.want) }
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+)
+
+// MaxProductTags caps the number of product tags on a post.
+const MaxProductTags = 20
+
+// ProductTag places a catalog product on one media item of a post. X/Y are
+// normalized to 0..1 from the top-left corner; for videos AtSeconds says
+// when the tag shows up.
+type ProductTag struct {
+	ProductID  string   `firestore:"productID"  json:"productID"`
+	MediaIndex int      `firestore:"mediaIndex" json:"mediaIndex"`
+	X          float64  `firestore:"x"          json:"x"`
+	Y          float64  `firestore:"y"          json:"y"`
+	AtSeconds  *float64 `firestore:"atSeconds,omitempty" json:"atSeconds,omitempty"`
+}
+
+// ProductSummary is the slice of a catalog product embedded in post responses.
+type ProductSummary struct {
+	ID        string `json:"id"        firestore:"-"`
+	Name      string `json:"name"      firestore:"name"`
+	BrandName string `json:"brandName" firestore:"brandName"`
+	Category  string `json:"category"  firestore:"category"`
+	ImageURL  string `json:"imageURL,omitempty" firestore:"imageURL"`
+
+	Ingredients []string `json:"-" firestore:"ingredients"` // for avoid-list warnings
+}
+
+// ProductTagResponse is a tag with its product resolved.
+type ProductTagResponse struct {
+	ProductTag
+	Product ProductSummary `json:"product"`
+
+	// AvoidMatches lists the product's ingredients on the viewer's avoid
+	// list; see MarkAvoided.
+	AvoidMatches []string `json:"avoidMatches,omitempty"`
+}
+
+// ProductIDs returns the distinct products tagged on the post, which is also
+// stored as "productIDs" for array-contains lookups.
+func ProductIDs(tags []ProductTag) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		if seen[t.ProductID] { continue }
+		seen[t.ProductID] = true
+		out = append(out, t.ProductID)
+	}
+	return out
+}
+
+// LoadProducts fetches product summaries in one round trip. Products that
+// no longer exist are absent from the result.
+func LoadProducts(ctx context.Context, fs *firestore.Client, ids []string) (map[string]ProductSummary, error) {
+	out := map[string]ProductSummary{}
+	var refs []*firestore.DocumentRef
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] || id == "" { continue }
+		seen[id] = true
+		refs = append(refs, fs.Collection("products").Doc(id))
+	}
+	if len(refs) == 0 { return out, nil }
+
+	docs, err := fs.GetAll(ctx, refs)
+	if err != nil { return out, err }
+	for _, d := range docs {
+		if !d.Exists() { continue }
+		var p ProductSummary
+		if err := d.DataTo(&p); err != nil { continue }
+		p.ID = d.Ref.ID
+		out[p.ID] = p
+	}
+	return out, nil
+}
+
+// FillProductTags resolves the products of every tag, and of every
+// before/after step, in posts in place; deleted products are dropped.
+func FillProductTags(ctx context.Context, fs *firestore.Client, posts []PostResponse) {
+	var ids []string
+	for _, p := range posts {
+		for _, t := range p.ProductTags { ids = append(ids, t.ProductID) }
+		if p.BeforeAfter == nil { continue }
+		for _, s := range p.BeforeAfter.Steps { ids = append(ids, s.productIDs...) }
+	}
+	if len(ids) == 0 { return }
+	products, err := LoadProducts(ctx, fs, ids)
+	if err != nil { return }
+
+	for i := range posts {
+		kept := posts[i].ProductTags[:0]
+		for _, t := range posts[i].ProductTags {
+			p, ok := products[t.ProductID]
+			if !ok { continue }
+			t.Product = p
+			kept = append(kept, t)
+		}
+		posts[i].ProductTags = kept
+
+		if posts[i].BeforeAfter == nil { continue }
+		steps := posts[i].BeforeAfter.Steps
+		for j := range steps {
+			for _, id := range steps[j].productIDs {
+				if p, ok := products[id]; ok { steps[j].Products = append(steps[j].Products, p) }
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Saves live at users/{uid}/saves/{postID} and are private to uid; the
+// post only keeps an aggregate saveCount that its author can see.
+
+// SaveRef is uid's save of postID.
+func SaveRef(fs *firestore.Client, uid, postID string) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("saves").Doc(postID)
+}
+
+// MarkSaved sets SavedByMe on posts uid has saved and exposes SaveCount on
+// uid's own posts. Counts are re-read rather than taken from the response,
+// which may come from a shared cache. Anonymous callers are left untouched.
+func MarkSaved(ctx context.Context, fs *firestore.Client, uid string, posts []PostResponse) {
+	if uid == "" || len(posts) == 0 { return }
+	refs := make([]*firestore.DocumentRef, 0, len(posts))
+	for _, p := range posts { refs = append(refs, SaveRef(fs, uid, p.ID)) }
+	if docs, err := fs.GetAll(ctx, refs); err == nil {
+		for i, d := range docs { posts[i].SavedByMe = d.Exists() }
+	}
+
+	var own []int
+	refs = refs[:0]
+	for i, p := range posts {
+		if p.Author.ID != uid { continue }
+		own = append(own, i)
+		refs = append(refs, fs.Collection("posts").Doc(p.ID))
+	}
+	if len(refs) == 0 { return }
+	docs, err := fs.GetAll(ctx, refs)
+	if err != nil { return }
+	for j, d := range docs {
+		if !d.Exists() { continue }
+		n, _ := d.Data()["saveCount"].(int64)
+		posts[own[j]].SaveCount = &n
+	}
+}
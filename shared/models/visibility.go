@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Who may see a post:
+//
+//	public         anyone
+//	followers      the author's followers (users/{author}/followers/{uid})
+//	close_friends  the author's close friends (users/{author}/closeFriends/{uid})
+//	private        the author only
+//
+// The author always sees their own posts. Visibility says nothing about
+// status; callers still check IsLive.
+
+// FollowerRef is the edge recording that followerUID follows uid.
+func FollowerRef(fs *firestore.Client, uid, followerUID string) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("followers").Doc(followerUID)
+}
+
+// CloseFriendRef is the entry for friendUID on uid's close friends list.
+func CloseFriendRef(fs *firestore.Client, uid, friendUID string) *firestore.DocumentRef {
+	return fs.Collection("users").Doc(uid).Collection("closeFriends").Doc(friendUID)
+}
+
+// CanView reports whether viewer ("" when signed out) may see p.
+func CanView(ctx context.Context, fs *firestore.Client, viewer string, p Post) (bool, error) {
+	if p.IsPublic() || p.AuthorID == viewer { return true, nil }
+	ref := audienceRef(fs, viewer, p)
+	if ref == nil { return false, nil }
+	doc, err := ref.Get(ctx)
+	if doc != nil && !doc.Exists() { return false, nil } // NotFound
+	if err != nil { return false, err }
+	return true, nil
+}
+
+// FilterVisible keeps the posts viewer may see, in order. Follower and
+// close-friend edges are looked up once per author; if the lookup fails,
+// restricted posts are dropped rather than leaked.
+func FilterVisible(ctx context.Context, fs *firestore.Client, viewer string, posts []Post) []Post {
+	var refs []*firestore.DocumentRef
+	seen := map[string]bool{}
+	for _, p := range posts {
+		ref := audienceRef(fs, viewer, p)
+		if ref == nil || seen[ref.Path] { continue }
+		seen[ref.Path] = true
+		refs = append(refs, ref)
+	}
+	allowed := map[string]bool{}
+	if len(refs) > 0 {
+		if docs, err := fs.GetAll(ctx, refs); err == nil {
+			for _, d := range docs { allowed[d.Ref.Path] = d.Exists() }
+		}
+	}
+
+	out := make([]Post, 0, len(posts))
+	for _, p := range posts {
+		if p.IsPublic() || p.AuthorID == viewer {
+			out = append(out, p)
+		} else if ref := audienceRef(fs, viewer, p); ref != nil && allowed[ref.Path] {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// audienceRef is the edge that grants viewer access to a restricted post,
+// or nil when nothing can (private posts, signed-out viewers).
+func audienceRef(fs *firestore.Client, viewer string, p Post) *firestore.DocumentRef {
+	if viewer == "" || p.AuthorID == viewer { return nil }
+	switch p.Visibility {
+	case VisibilityFollowers:
+		return FollowerRef(fs, p.AuthorID, viewer)
+	case VisibilityCloseFriends:
+		return CloseFriendRef(fs, p.AuthorID, viewer)
+	}
+	return nil
+}
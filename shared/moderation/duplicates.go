@@ -0,0 +1,149 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+// Perceptual hashes of processed media (images, and video keyframes) are
+// indexed at posts/{id}/mediaHashes/{item}_{frame}, so they go when the post
+// does, and searched across posts through the collection group. Firestore
+// cannot search by Hamming distance, so each hash is also stored as four
+// 16-bit bands: hashes at most three bits apart always share a band, and
+// candidates sharing one are then checked for the real distance. Matches
+// further apart are still found when their differing bits cluster.
+//
+// Candidates are read oldest first and only from posts older than the one
+// checked, so the original is among them however common its bands are.
+// The search needs a collection group index on mediaHashes over bands
+// (array-contains) and timestamp (ascending).
+
+const (
+	hashBands     = 4
+	maxCandidates = 300
+
+	// MaxHashesPerItem caps the hashes searched per media item: four bands
+	// each must fit the 30 values of one array-contains-any query.
+	MaxHashesPerItem = 7
+)
+
+// LabelDuplicate is the moderation label of near-duplicate media.
+const LabelDuplicate = "duplicate"
+
+// MediaHash is posts/{postID}/mediaHashes/{item}_{frame}.
+type MediaHash struct {
+	PostID     string    `firestore:"postID"`
+	AuthorID   string    `firestore:"authorID"`
+	MediaIndex int       `firestore:"mediaIndex"`
+	Frame      int       `firestore:"frame"` // 0 for images, keyframe number for videos
+	Hash       string    `firestore:"hash"`  // FormatHash
+	Bands      []string  `firestore:"bands"`
+	Timestamp  time.Time `firestore:"timestamp"` // the post's, for ordering candidates
+}
+
+func bandsOf(h uint64) []string {
+	out := make([]string, hashBands)
+	for i := range out { out[i] = fmt.Sprintf("%d:%04x", i, (h>>(16*i))&0xffff) }
+	return out
+}
+
+// IndexMedia stores the hashes of media item idx of post.
+func IndexMedia(ctx context.Context, fs *firestore.Client, post models.Post, idx int, hashes []uint64) error {
+	if len(hashes) == 0 { return nil }
+	b := fs.Batch()
+	for i, h := range hashes {
+		ref := fs.Collection("posts").Doc(post.ID).Collection("mediaHashes").Doc(fmt.Sprintf("%d_%d", idx, i))
+		b.Set(ref, MediaHash{PostID: post.ID, AuthorID: post.AuthorID, MediaIndex: idx, Frame: i,
+			Hash: FormatHash(h), Bands: bandsOf(h), Timestamp: post.Timestamp})
+	}
+	_, err := b.Commit(ctx)
+	return err
+}
+
+// FindOriginal looks for the earliest published post by someone other than
+// post's author whose media is within maxDistance bits of any of hashes,
+// and returns nil when there is none. idx is the media item the hashes
+// belong to.
+func FindOriginal(ctx context.Context, fs *firestore.Client, post models.Post, idx int, hashes []uint64, maxDistance int) (*models.Duplicate, error) {
+	if len(hashes) > MaxHashesPerItem { hashes = hashes[:MaxHashesPerItem] }
+	var bands []string
+	for _, h := range hashes { bands = append(bands, bandsOf(h)...) }
+	if len(bands) == 0 { return nil, nil }
+
+	docs, err := fs.CollectionGroup("mediaHashes").Where("bands", "array-contains-any", bands).
+		Where("timestamp", "<", post.Timestamp).OrderBy("timestamp", firestore.Asc).
+		Limit(maxCandidates).Documents(ctx).GetAll()
+	if err != nil { return nil, err }
+
+	closest := map[string]int{} // candidate post → smallest distance
+	var refs []*firestore.DocumentRef
+	for _, d := range docs {
+		var mh MediaHash
+		if err := d.DataTo(&mh); err != nil || mh.AuthorID == post.AuthorID || mh.PostID == post.ID { continue }
+		other, err := ParseHash(mh.Hash)
+		if err != nil { continue }
+		for _, h := range hashes {
+			dist := Distance(h, other)
+			if dist > maxDistance { continue }
+			if best, seen := closest[mh.PostID]; !seen {
+				closest[mh.PostID] = dist
+				refs = append(refs, fs.Collection("posts").Doc(mh.PostID))
+			} else if dist < best {
+				closest[mh.PostID] = dist
+			}
+		}
+	}
+	if len(refs) == 0 { return nil, nil }
+
+	// candidates may be drafts, reposts themselves or mid-deletion
+	posted, err := fs.GetAll(ctx, refs)
+	if err != nil { return nil, err }
+	var orig *models.Post
+	for _, d := range posted {
+		if !d.Exists() { continue }
+		p, err := models.PostFromDoc(d)
+		if err != nil || !p.IsPublished() || !p.Timestamp.Before(post.Timestamp) { continue }
+		if orig == nil || p.Timestamp.Before(orig.Timestamp) { orig = &p }
+	}
+	if orig == nil { return nil, nil }
+	return &models.Duplicate{
+		PostID: orig.ID, AuthorID: orig.AuthorID, MediaIndex: idx,
+		Distance: closest[orig.ID], DetectedAt: time.Now().UTC(),
+	}, nil
+}
+
+// FlagDuplicate records dup on post postID and sends the post to review
+// under the source "duplicate:<idx>". With credit set, the post is also
+// attributed to the original's creator unless it already credits someone.
+func FlagDuplicate(ctx context.Context, fs *firestore.Client, postID string, dup models.Duplicate, credit bool) error {
+	ref := fs.Collection("posts").Doc(postID)
+	err := fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		post, err := models.PostFromDoc(doc)
+		if err != nil { return err }
+
+		var updates []firestore.Update
+		// several items may match; the first match found stays
+		if post.DuplicateOf == nil { updates = append(updates, firestore.Update{Path: "duplicateOf", Value: dup}) }
+		if credit && post.Credit == nil {
+			updates = append(updates, firestore.Update{Path: "credit", Value: models.Credit{PostID: dup.PostID, AuthorID: dup.AuthorID, Auto: true}})
+		}
+		if len(updates) == 0 { return nil }
+		return tx.Update(ref, updates)
+	})
+	if err != nil { return err }
+
+	res := Result{
+		Decision: models.ModerationReview,
+		Labels:   []string{LabelDuplicate},
+		Reason:   fmt.Sprintf("media %d matches post %s by %s (distance %d)", dup.MediaIndex, dup.PostID, dup.AuthorID, dup.Distance),
+	}
+	_, _, err = ApplyToPost(ctx, fs, postID, fmt.Sprintf("duplicate:%d", dup.MediaIndex), res)
+	return err
+}
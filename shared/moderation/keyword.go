@@ -0,0 +1,85 @@
+package moderation
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+// The keyword classifier matches text against the case-insensitive regex
+// rules in rules.json, or in the file named by MODERATION_RULES when set.
+// Each rule has a label and the decision it triggers; the most severe match
+// wins. It cannot see images and allows all of them, so deployments that
+// need image checks should register a classifier backed by a vision API.
+
+//go:embed rules.json
+var defaultRules []byte
+
+type rule struct {
+	Label    string   `json:"label"`
+	Decision string   `json:"decision"`
+	Patterns []string `json:"patterns"`
+	res      []*regexp.Regexp
+}
+
+// Keyword is the regex rule classifier.
+type Keyword struct{ rules []rule }
+
+func init() {
+	Register("keyword", func() (Classifier, error) {
+		data := defaultRules
+		if path := os.Getenv("MODERATION_RULES"); path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil { return nil, fmt.Errorf("moderation: read rules: %w", err) }
+			data = b
+		}
+		return NewKeyword(data)
+	})
+}
+
+// NewKeyword compiles a rules document in the rules.json format.
+func NewKeyword(data []byte) (*Keyword, error) {
+	var rules []rule
+	if err := json.Unmarshal(data, &rules); err != nil { return nil, fmt.Errorf("moderation: bad rules: %w", err) }
+	for i := range rules {
+		r := &rules[i]
+		if r.Decision != models.ModerationReview && r.Decision != models.ModerationBlock {
+			return nil, fmt.Errorf("moderation: rule %q: decision must be review or block", r.Label)
+		}
+		for _, p := range r.Patterns {
+			re, err := regexp.Compile("(?i)" + p)
+			if err != nil { return nil, fmt.Errorf("moderation: rule %q: %w", r.Label, err) }
+			r.res = append(r.res, re)
+		}
+	}
+	return &Keyword{rules: rules}, nil
+}
+
+func (k *Keyword) ClassifyText(_ context.Context, text string) (Result, error) {
+	res := Result{Decision: models.ModerationAllow}
+	var hits []string
+	for _, r := range k.rules {
+		for _, re := range r.res {
+			m := re.FindString(text)
+			if m == "" { continue }
+			res.Labels = append(res.Labels, r.Label)
+			hits = append(hits, fmt.Sprintf("%s: %q", r.Label, m))
+			if models.MoreSevere(r.Decision, res.Decision) { res.Decision = r.Decision }
+			break
+		}
+	}
+	sort.Strings(res.Labels)
+	res.Reason = strings.Join(hits, "; ")
+	return res, nil
+}
+
+func (k *Keyword) ClassifyImage(context.Context, Image) (Result, error) {
+	return Result{Decision: models.ModerationAllow}, nil
+}
@@ -0,0 +1,111 @@
+// Package moderation classifies user content and records the decisions.
+//
+// Classifiers are pluggable: anything implementing Classifier can be
+// registered under a name and picked with MODERATION_CLASSIFIER. The
+// default, "keyword", matches text against regex rules and lets every image
+// through; see keyword.go.
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+// Result is a classifier verdict: one of the models.Moderation* decisions,
+// the labels that triggered it and a short human-readable reason.
+type Result = models.ModerationCheck
+
+// Image is a picture to classify: Path is a local copy, Object the same
+// picture in the media bucket, for classifiers that read from storage
+// themselves, and ContentType its type. All three describe one file; a
+// video is classified by its thumbnail, so both paths name the thumbnail.
+type Image struct {
+	Path        string
+	Object      string
+	ContentType string
+}
+
+// Classifier judges text and images.
+type Classifier interface {
+	ClassifyText(ctx context.Context, text string) (Result, error)
+	ClassifyImage(ctx context.Context, img Image) (Result, error)
+}
+
+// Factory builds a classifier; it is called once per FromEnv.
+type Factory func() (Classifier, error)
+
+var (
+	mu        sync.Mutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a classifier available under name. It is meant to be
+// called from init functions.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// New builds the classifier registered under name.
+func New(name string) (Classifier, error) {
+	mu.Lock()
+	f, ok := factories[name]
+	mu.Unlock()
+	if !ok { return nil, fmt.Errorf("moderation: unknown classifier %q (have %v)", name, names()) }
+	return f()
+}
+
+// FromEnv builds the classifier named by MODERATION_CLASSIFIER, "keyword"
+// by default.
+func FromEnv() (Classifier, error) {
+	name := os.Getenv("MODERATION_CLASSIFIER")
+	if name == "" { name = "keyword" }
+	return New(name)
+}
+
+func names() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	out := make([]string, 0, len(factories))
+	for n := range factories { out = append(out, n) }
+	sort.Strings(out)
+	return out
+}
+
+// Text classifies text, sending it to review when the classifier fails so
+// that an outage never waves content through unseen.
+func Text(ctx context.Context, c Classifier, text string) Result {
+	if text == "" { return Result{Decision: models.ModerationAllow} }
+	res, err := c.ClassifyText(ctx, text)
+	if err != nil {
+		log.Printf("moderation: classify text: %v", err)
+		return Result{Decision: models.ModerationReview, Labels: []string{"classifier_error"}}
+	}
+	return normalize(res)
+}
+
+// Picture is Text for images.
+func Picture(ctx context.Context, c Classifier, img Image) Result {
+	res, err := c.ClassifyImage(ctx, img)
+	if err != nil {
+		log.Printf("moderation: classify %s: %v", img.Object, err)
+		return Result{Decision: models.ModerationReview, Labels: []string{"classifier_error"}}
+	}
+	return normalize(res)
+}
+
+func normalize(r Result) Result {
+	switch r.Decision {
+	case models.ModerationAllow, models.ModerationReview, models.ModerationBlock:
+	default:
+		r.Decision = models.ModerationReview // unknown verdicts get a human look
+	}
+	return r
+}
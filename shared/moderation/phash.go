@@ -0,0 +1,71 @@
+package moderation
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"sort"
+	"strconv"
+)
+
+// PHash is the 64-bit DCT perceptual hash of img: the image is reduced to
+// 32×32 luma, transformed, and each of the 8×8 lowest frequencies becomes
+// one bit, set when it is above their median. Re-encoding, resizing, light
+// filters and watermarks move only a few bits, so near-identical pictures
+// have a small Distance.
+//
+// Callers should pass a small rendition; anything larger than 32×32 is
+// sampled down by nearest neighbour.
+func PHash(img image.Image) uint64 {
+	const n = 32
+	b := img.Bounds()
+	if b.Dx() == 0 || b.Dy() == 0 { return 0 }
+
+	var px [n][n]float64
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x*b.Dx()/n, b.Min.Y+y*b.Dy()/n).RGBA()
+			px[y][x] = 0.299*float64(r>>8) + 0.587*float64(g>>8) + 0.114*float64(bl>>8)
+		}
+	}
+
+	// 2-D DCT-II, only the 8×8 low-frequency corner is needed
+	var cos [8][n]float64
+	for u := 0; u < 8; u++ {
+		for x := 0; x < n; x++ { cos[u][x] = math.Cos(float64(2*x+1) * float64(u) * math.Pi / (2 * n)) }
+	}
+	coeffs := make([]float64, 0, 64)
+	for v := 0; v < 8; v++ {
+		for u := 0; u < 8; u++ {
+			var sum float64
+			for y := 0; y < n; y++ {
+				for x := 0; x < n; x++ { sum += px[y][x] * cos[u][x] * cos[v][y] }
+			}
+			coeffs = append(coeffs, sum)
+		}
+	}
+
+	// the DC term is the overall brightness and would skew the median
+	sorted := append([]float64(nil), coeffs[1:]...)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	var h uint64
+	for i, c := range coeffs {
+		if c > median { h |= 1 << uint(63-i) }
+	}
+	return h
+}
+
+// Distance is the number of differing bits between two hashes.
+func Distance(a, b uint64) int { return bits.OnesCount64(a ^ b) }
+
+// FormatHash renders a hash as the 16 hex digits it is stored as.
+func FormatHash(h uint64) string {
+	s := strconv.FormatUint(h, 16)
+	for len(s) < 16 { s = "0" + s }
+	return s
+}
+
+// ParseHash reverses FormatHash.
+func ParseHash(s string) (uint64, error) { return strconv.ParseUint(s, 16, 64) }
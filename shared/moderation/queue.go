@@ -0,0 +1,153 @@
+package moderation
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+// Everything a moderator should look at lands in moderationQueue, one item
+// per target (post, comment, message or user), so classifier flags and
+// user reports about the same thing meet in one place and a moderator sees
+// its latest state rather than a trail of checks. An item is reopened
+// whenever its target is flagged or reported again and cleared when an
+// edit makes flagged content acceptable.
+
+const (
+	KindPost    = "post"
+	KindComment = "comment"
+	KindMessage = "message"
+	KindUser    = "user"
+)
+
+// Queue item states.
+const (
+	QueueOpen     = "open"
+	QueueClaimed  = "claimed"  // a moderator is working on it
+	QueueResolved = "resolved" // a moderator decided, see Resolution
+	QueueCleared  = "cleared"  // an edit made the content acceptable
+)
+
+// QueueItem is moderationQueue/{id}. Reports by individual users are kept
+// below it in reports/{reporterUID}.
+type QueueItem struct {
+	ID          string           `firestore:"-"                    json:"id"`
+	Kind        string           `firestore:"kind"                 json:"kind"`
+	TargetID    string           `firestore:"targetID"             json:"targetID"` // post, comment, message or user ID
+	PostID      string           `firestore:"postID"               json:"postID,omitempty"`
+	CommentID   string           `firestore:"commentID"            json:"commentID,omitempty"`
+	AuthorID    string           `firestore:"authorID"             json:"authorID"` // who wrote it; the user for profiles
+	Decision    string           `firestore:"decision"             json:"decision,omitempty"` // classifier's, "" if only reported
+	Labels      []string         `firestore:"labels"               json:"labels"`
+	Reason      string           `firestore:"reason"               json:"reason,omitempty"`
+	Source      string           `firestore:"source"               json:"source,omitempty"` // what was last checked: caption, text, media:<i>
+	ReportCount int64            `firestore:"reportCount"          json:"reportCount"`
+	Reasons     map[string]int64 `firestore:"reasons,omitempty"    json:"reasons,omitempty"` // report reason → reporters
+	Status      string           `firestore:"status"               json:"status"`
+	ClaimedBy   string           `firestore:"claimedBy,omitempty"  json:"claimedBy,omitempty"`
+	ClaimedAt   time.Time        `firestore:"claimedAt,omitempty"  json:"claimedAt,omitempty"`
+	Resolution  *Resolution      `firestore:"resolution,omitempty" json:"resolution,omitempty"`
+	UpdatedAt   time.Time        `firestore:"updatedAt"            json:"updatedAt"`
+}
+
+// Resolution is the moderator decision that closed an item.
+type Resolution struct {
+	Action      string    `firestore:"action"      json:"action"`
+	Note        string    `firestore:"note"        json:"note,omitempty"`
+	ModeratorID string    `firestore:"moderatorID" json:"moderatorID"`
+	At          time.Time `firestore:"at"          json:"at"`
+}
+
+// ItemID names the queue item for a target. Comments are only unique per
+// post, hence postID.
+func ItemID(kind, targetID, postID string) string {
+	if kind == KindComment { return kind + "_" + postID + "_" + targetID }
+	return kind + "_" + targetID
+}
+
+// ItemRef is moderationQueue/{id}.
+func ItemRef(fs *firestore.Client, id string) *firestore.DocumentRef {
+	return fs.Collection("moderationQueue").Doc(id)
+}
+
+// QueueRef is the queue item for a post (commentID "") or a comment.
+func QueueRef(fs *firestore.Client, postID, commentID string) *firestore.DocumentRef {
+	if commentID != "" { return ItemRef(fs, ItemID(KindComment, commentID, postID)) }
+	return ItemRef(fs, ItemID(KindPost, postID, ""))
+}
+
+// ItemFromDoc decodes a queue item.
+func ItemFromDoc(doc *firestore.DocumentSnapshot) (QueueItem, error) {
+	var it QueueItem
+	err := doc.DataTo(&it)
+	it.ID = doc.Ref.ID
+	if it.Labels == nil { it.Labels = []string{} }
+	return it, err
+}
+
+// queueData is the write that moves the item for content whose decision
+// went from before to m, or nil when the queue is unaffected. Content a
+// moderator already decided on goes back to them whenever it changes, even
+// if the classifier is happy with it: the decision was about what it said
+// before.
+func queueData(item QueueItem, before string, m models.Moderation, source string) map[string]any {
+	check := m.Sources[source]
+	switch {
+	case m.Decision != models.ModerationAllow || m.Override != "":
+		kind, target := KindPost, item.PostID
+		if item.CommentID != "" { kind, target = KindComment, item.CommentID }
+		return map[string]any{
+			"kind": kind, "targetID": target, "postID": item.PostID, "commentID": item.CommentID, "authorID": item.AuthorID,
+			"decision": m.Decision, "labels": nonNil(m.Labels), "reason": check.Reason, "source": source,
+			"status": QueueOpen, "updatedAt": firestore.ServerTimestamp,
+		}
+	case before != "" && before != models.ModerationAllow:
+		return map[string]any{"decision": m.Decision, "labels": []string{}, "source": source,
+			"status": QueueCleared, "updatedAt": firestore.ServerTimestamp}
+	}
+	return nil
+}
+
+// Enqueue records the outcome of checking source on a post or comment:
+// item names the content (PostID, CommentID, AuthorID), before is its
+// previous overall decision and m the new state.
+func Enqueue(ctx context.Context, fs *firestore.Client, item QueueItem, before string, m models.Moderation, source string) error {
+	data := queueData(item, before, m, source)
+	if data == nil { return nil }
+	_, err := QueueRef(fs, item.PostID, item.CommentID).Set(ctx, data, firestore.MergeAll)
+	return err
+}
+
+// EnqueueTx is Enqueue inside a transaction.
+func EnqueueTx(tx *firestore.Transaction, fs *firestore.Client, item QueueItem, before string, m models.Moderation, source string) error {
+	data := queueData(item, before, m, source)
+	if data == nil { return nil }
+	return tx.Set(QueueRef(fs, item.PostID, item.CommentID), data, firestore.MergeAll)
+}
+
+// ApplyToPost records res for source on post postID and queues it if
+// needed. Sources finish independently (caption edits, each media item),
+// hence the transaction. It returns the post's state before and after.
+func ApplyToPost(ctx context.Context, fs *firestore.Client, postID, source string, res Result) (before, after models.Moderation, err error) {
+	ref := fs.Collection("posts").Doc(postID)
+	err = fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		post, err := models.PostFromDoc(doc)
+		if err != nil { return err }
+		before, after = post.Moderation, post.Moderation.With(source, res)
+
+		item := QueueItem{PostID: postID, AuthorID: post.AuthorID}
+		if err := EnqueueTx(tx, fs, item, before.Decision, after, source); err != nil { return err }
+		return tx.Update(ref, []firestore.Update{{Path: "moderation", Value: after}})
+	})
+	return before, after, err
+}
+
+func nonNil(s []string) []string {
+	if s == nil { return []string{} }
+	return s
+}
@@ -0,0 +1,36 @@
+package paging
+
+import (
+	"net/http"
+	"strconv"
+
+	"cloud.google.com/go/firestore"
+)
+
+// Page is the JSON envelope of every paginated list endpoint. NextCursor is
+// opaque to clients: pass it back as ?cursor= to fetch the next page.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// Limit reads ?limit=, falling back to def and capping at max.
+func Limit(r *http.Request, def, max int) int {
+	n, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || n <= 0 { return def }
+	if n > max { return max }
+	return n
+}
+
+// Cursor reads ?cursor=.
+func Cursor(r *http.Request) string { return r.URL.Query().Get("cursor") }
+
+// StartAfter resumes q after the document named by ?cursor= in col; list
+// handlers hand out the ID of their last document as NextCursor.
+func StartAfter(r *http.Request, col *firestore.CollectionRef, q firestore.Query) (firestore.Query, error) {
+	cur := Cursor(r)
+	if cur == "" { return q, nil }
+	snap, err := col.Doc(cur).Get(r.Context())
+	if err != nil { return q, err }
+	return q.StartAfter(snap), nil
+}
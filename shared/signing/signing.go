@@ -0,0 +1,77 @@
+package signing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+)
+
+// Read URLs live for readTTL and are handed out again until less than
+// minRemaining is left, so feeds cached upstream never embed a dead link.
+const (
+	readTTL      = time.Hour
+	minRemaining = 20 * time.Minute
+)
+
+var (
+	store blobstore.Store
+
+	mu    sync.Mutex
+	cache = map[string]cachedURL{}
+)
+
+type cachedURL struct {
+	url     string
+	expires time.Time
+}
+
+// Init sets the store URLs are signed by. Call once from main().
+func Init(s blobstore.Store) { store = s }
+
+// Enabled reports whether Init was given a store that can sign.
+func Enabled() bool { return store != nil && store.CanSign() }
+
+// UploadURL returns a signed PUT URL for object, valid for ttl, and the
+// headers the client must send with it. Stores that support it reject
+// uploads other than contentType or larger than maxBytes.
+func UploadURL(object, contentType string, maxBytes int64, ttl time.Duration) (string, map[string]string, error) {
+	if !Enabled() { return "", nil, fmt.Errorf("signing not initialised") }
+	return store.UploadURL(object, contentType, maxBytes, time.Now().Add(ttl))
+}
+
+// ReadURL returns a short-lived signed GET URL for object, reusing a cached
+// one while it still has enough lifetime left.
+func ReadURL(object string) (string, error) {
+	now := time.Now()
+	mu.Lock()
+	c, ok := cache[object]
+	mu.Unlock()
+	if ok && c.expires.Sub(now) > minRemaining { return c.url, nil }
+
+	expires := now.Add(readTTL)
+	if !Enabled() { return "", fmt.Errorf("signing not initialised") }
+	url, err := store.ReadURL(object, expires)
+	if err != nil { return "", err }
+
+	mu.Lock()
+	if len(cache) > 50_000 { evictExpired(now) }
+	cache[object] = cachedURL{url: url, expires: expires}
+	mu.Unlock()
+	return url, nil
+}
+
+// Forget drops any cached URL for object (e.g. after it was deleted).
+func Forget(object string) {
+	mu.Lock()
+	delete(cache, object)
+	mu.Unlock()
+}
+
+// evictExpired must be called with mu held.
+func evictExpired(now time.Time) {
+	for k, c := range cache {
+		if c.expires.Sub(now) <= minRemaining { delete(cache, k) }
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -13,7 +14,10 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/counters"
 	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/inci"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
 )
 
 var (
@@ -44,6 +48,11 @@ func main() {
 	r.Put("/users/{id}", updateProfile)
 	r.Post("/users/{id}/follow", followUser)
 	r.Delete("/users/{id}/follow", unfollowUser)
+	r.Get("/users/{id}/close-friends", listCloseFriends)
+	r.Put("/users/{id}/close-friends/{friendID}", addCloseFriend)
+	r.Delete("/users/{id}/close-friends/{friendID}", removeCloseFriend)
+	r.Get("/users/{id}/avoid-ingredients", getAvoidList)
+	r.Put("/users/{id}/avoid-ingredients", putAvoidList)
 	r.Get("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("user-svc OK")) })
 
 	log.Printf("user-service listening on :%s", port)
@@ -58,6 +67,11 @@ func getProfile(w http.ResponseWriter, r *http.Request) {
 	_ = json.NewEncoder(w).Encode(doc.Data())
 }
 
+// serverOwnedFields are kept up to date by the backend, never by clients:
+// usernameLower is derived from username (mentions match on it), the
+// counters change with follows and counterShards is the counter layout.
+var serverOwnedFields = []string{models.UsernameLowerField, "followersCount", "followingCount", counters.ShardsField}
+
 func updateProfile(w http.ResponseWriter, r *http.Request) {
 	uid := chi.URLParam(r, "id")
 	me, err := auth.VerifyFirebaseToken(r.Context(), r)
@@ -66,6 +80,11 @@ func updateProfile(w http.ResponseWriter, r *http.Request) {
 
 	var updates map[string]any
 	_ = json.NewDecoder(r.Body).Decode(&updates)
+	for _, f := range serverOwnedFields { delete(updates, f) }
+	if name, ok := updates["username"].(string); ok { updates[models.UsernameLowerField] = strings.ToLower(name) }
+	if p, ok := updates["mentionPolicy"]; ok { // who may @mention this user: everyone | following | nobody
+		if s, _ := p.(string); !models.MentionPolicies[s] { http.Error(w, "invalid mentionPolicy", 400); return }
+	}
 	_, err = fs.Collection("users").Doc(uid).Set(r.Context(), updates, firestore.MergeAll)
 	if err != nil { http.Error(w, err.Error(), 500); return }
 	w.WriteHeader(204)
@@ -77,14 +96,18 @@ func followUser(w http.ResponseWriter, r *http.Request) {
 	if err != nil { http.Error(w, "unauth", 401); return }
 	if follower == target { http.Error(w, "bad request", 400); return }
 
+	followerRef, targetRef := fs.Collection("users").Doc(follower), fs.Collection("users").Doc(target)
+	shards := counters.ShardsOf(r.Context(), targetRef) // followersCount is the hot one
+
 	b := fs.Batch()
-	b.Set(fs.Collection("users").Doc(follower).Collection("following").Doc(target), map[string]any{})
-	b.Set(fs.Collection("users").Doc(target).Collection("followers").Doc(follower), map[string]any{})
-	b.Update(fs.Collection("users").Doc(follower), []firestore.Update{{Path: "followingCount", Value: firestore.Increment(1)}})
-	b.Update(fs.Collection("users").Doc(target),   []firestore.Update{{Path: "followersCount", Value: firestore.Increment(1)}})
+	b.Set(followerRef.Collection("following").Doc(target), map[string]any{})
+	b.Set(targetRef.Collection("followers").Doc(follower), map[string]any{})
+	b.Update(followerRef, []firestore.Update{{Path: "followingCount", Value: firestore.Increment(1)}})
+	counters.AddBatch(b, targetRef, shards, "followersCount", 1)
 	if _, err := b.Commit(r.Context()); err != nil {
 		http.Error(w, err.Error(), 500); return
 	}
+	counters.Seen(r.Context(), fs, targetRef, shards)
 	events.Publish(r.Context(), topic, "USER_FOLLOWED", map[string]string{
 		"followerID": follower, "targetID": target,
 	})
@@ -96,11 +119,107 @@ func unfollowUser(w http.ResponseWriter, r *http.Request) {
 	follower, err := auth.VerifyFirebaseToken(r.Context(), r)
 	if err != nil { http.Error(w, "unauth", 401); return }
 
+	followerRef, targetRef := fs.Collection("users").Doc(follower), fs.Collection("users").Doc(target)
 	b := fs.Batch()
-	b.Delete(fs.Collection("users").Doc(follower).Collection("following").Doc(target))
-	b.Delete(fs.Collection("users").Doc(target).Collection("followers").Doc(follower))
-	b.Update(fs.Collection("users").Doc(follower), []firestore.Update{{Path: "followingCount", Value: firestore.Increment(-1)}})
-	b.Update(fs.Collection("users").Doc(target),   []firestore.Update{{Path: "followersCount", Value: firestore.Increment(-1)}})
+	b.Delete(followerRef.Collection("following").Doc(target))
+	b.Delete(targetRef.Collection("followers").Doc(follower))
+	b.Update(followerRef, []firestore.Update{{Path: "followingCount", Value: firestore.Increment(-1)}})
+	counters.AddBatch(b, targetRef, counters.ShardsOf(r.Context(), targetRef), "followersCount", -1)
 	_, _ = b.Commit(r.Context())
 	w.WriteHeader(204)
 }
+
+// Close friends see posts shared with visibility "close_friends". The list
+// is private to its owner; friends are not told they are on it.
+
+const maxCloseFriends = 500
+
+func listCloseFriends(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "id")
+	me, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", 401); return }
+	if me != uid { http.Error(w, "forbidden", 403); return }
+
+	docs, err := fs.Collection("users").Doc(uid).Collection("closeFriends").
+		OrderBy("addedAt", firestore.Desc).Documents(r.Context()).GetAll()
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	ids := make([]string, 0, len(docs))
+	for _, d := range docs { ids = append(ids, d.Ref.ID) }
+	authors, err := models.LoadAuthors(r.Context(), fs, ids)
+	if err != nil { http.Error(w, err.Error(), 500); return }
+
+	out := make([]models.AuthorSummary, 0, len(ids))
+	for _, id := range ids { out = append(out, authors[id]) }
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+func addCloseFriend(w http.ResponseWriter, r *http.Request) {
+	uid, friend := chi.URLParam(r, "id"), chi.URLParam(r, "friendID")
+	me, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", 401); return }
+	if me != uid { http.Error(w, "forbidden", 403); return }
+	if friend == uid { http.Error(w, "bad request", 400); return }
+	if _, err := fs.Collection("users").Doc(friend).Get(r.Context()); err != nil { http.Error(w, "not found", 404); return }
+
+	n, err := fs.Collection("users").Doc(uid).Collection("closeFriends").Select().Documents(r.Context()).GetAll()
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	if len(n) >= maxCloseFriends { http.Error(w, "close friends list full", 400); return }
+
+	_, err = models.CloseFriendRef(fs, uid, friend).Set(r.Context(), map[string]any{"addedAt": firestore.ServerTimestamp})
+	if err != nil { http.Error(w, err.Error(), 500); return }
+	w.WriteHeader(204)
+}
+
+func removeCloseFriend(w http.ResponseWriter, r *http.Request) {
+	uid, friend := chi.URLParam(r, "id"), chi.URLParam(r, "friendID")
+	me, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", 401); return }
+	if me != uid { http.Error(w, "forbidden", 403); return }
+
+	if _, err := models.CloseFriendRef(fs, uid, friend).Delete(r.Context()); err != nil {
+		http.Error(w, err.Error(), 500); return
+	}
+	w.WriteHeader(204)
+}
+
+// The ingredient avoid list is private: only its owner may read or replace it.
+
+func getAvoidList(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "id")
+	me, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", 401); return }
+	if me != uid { http.Error(w, "forbidden", 403); return }
+
+	l := models.AvoidList{Avoid: models.LoadAvoidList(r.Context(), fs, uid)}
+	if l.Avoid == nil { l.Avoid = []string{} }
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
+
+// putAvoidList stores canonical INCI names, so "Fragrance" and "Parfum" are
+// the same entry; flags such as "allergen" are kept as-is.
+func putAvoidList(w http.ResponseWriter, r *http.Request) {
+	uid := chi.URLParam(r, "id")
+	me, err := auth.VerifyFirebaseToken(r.Context(), r)
+	if err != nil { http.Error(w, "unauth", 401); return }
+	if me != uid { http.Error(w, "forbidden", 403); return }
+
+	var req models.AvoidList
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil { http.Error(w, "bad json", 400); return }
+	if len(req.Avoid) > models.MaxAvoidIngredients { http.Error(w, "avoid list too long", 400); return }
+
+	l := models.AvoidList{Avoid: []string{}}
+	seen := map[string]bool{}
+	for _, a := range req.Avoid {
+		if inci.IsFlag(a) { a = inci.Key(a) } else { a = inci.Normalize(a) }
+		if a == "" || seen[inci.Key(a)] { continue }
+		seen[inci.Key(a)] = true
+		l.Avoid = append(l.Avoid, a)
+	}
+	if _, err := models.AvoidListRef(fs, uid).Set(r.Context(), l); err != nil {
+		http.Error(w, err.Error(), 500); return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(l)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// picture draws a w×h image pixel by pixel.
+func picture(w, h int, f func(x, y int) color.RGBA) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ { img.Set(x, y, f(x, y)) }
+	}
+	return img
+}
+
+// The expected hashes come from the reference TypeScript encoder
+// (github.com/woltapp/blurhash) run on the same pixels.
+func TestBlurHash(t *testing.T) {
+	white := func(x, y int) color.RGBA { return color.RGBA{255, 255, 255, 255} }
+	black := func(x, y int) color.RGBA { return color.RGBA{0, 0, 0, 255} }
+	gradient := func(x, y int) color.RGBA { return color.RGBA{uint8(x * 8), uint8(y * 10), 128, 255} }
+	split := func(x, y int) color.RGBA {
+		if x < 10 { return color.RGBA{200, 30, 60, 255} }
+		return color.RGBA{20, 90, 220, 255}
+	}
+	tests := []struct {
+		name         string
+		img          image.Image
+		xComp, yComp int
+		want         string
+	}{
+		{"white", picture(16, 12, white), 4, 3, "LRTSUA_3fQ_3~qoffQoffQfQfQfQ"},
+		{"black", picture(16, 12, black), 4, 3, "L00000" + strings.Repeat("fQ", 11)},
+		{"gradient", picture(32, 24, gradient), 4, 3, "LxH27k2swxX8mHWWjtf7gJfjfQfj"},
+		{"DC only", picture(32, 24, gradient), 1, 1, "00H27k"},
+		{"two halves 5x4", picture(20, 20, split), 5, 4, "V%G=}h{ts7ObfQoNn$jsbHfQfQfQfQfQfQoNn$jsbHfQ"},
+		{"empty image", image.NewRGBA(image.Rect(0, 0, 0, 0)), 4, 3, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blurHash(tt.img, tt.xComp, tt.yComp); got != tt.want {
+				t.Errorf("blurHash = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBase83(t *testing.T) {
+	tests := []struct {
+		v, length int
+		want      string
+	}{
+		{0, 1, "0"},
+		{82, 1, "~"},
+		{83, 2, "10"},
+		{21, 1, "L"},
+		{16777215, 4, "TSUA"},
+	}
+	for _, tt := range tests {
+		if got := base83(tt.v, tt.length); got != tt.want { t.Errorf("base83(%d, %d) = %q, want %q", tt.v, tt.length, got, tt.want) }
+	}
+}
+
+func TestSRGBRoundTrip(t *testing.T) {
+	for v := uint32(0); v < 256; v++ {
+		if got := linearToSRGB(srgbToLinear(v)); got != int(v) { t.Errorf("linearToSRGB(srgbToLinear(%d)) = %d", v, got) }
+	}
+}
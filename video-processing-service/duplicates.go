@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	_ "image/png"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+)
+
+// Duplicate detection: every processed image, and up to maxKeyframes
+// keyframes of every video, is perceptually hashed and indexed (see
+// moderation.FindOriginal). An item near-matching an earlier post by
+// someone else sends the post to review with the original attached, and
+// credits the original's creator when DUPLICATE_AUTO_CREDIT=true.
+
+const maxKeyframes = 6
+
+var (
+	dupMaxDistance = envInt("DUPLICATE_MAX_DISTANCE", 6) // differing bits of 64 still counted as a match
+	dupAutoCredit  = os.Getenv("DUPLICATE_AUTO_CREDIT") == "true"
+)
+
+// imageHashes hashes a 32×32 grey rendition of src, turned upright.
+func imageHashes(tmpBase, src, upright string) ([]uint64, error) {
+	small := tmpBase + "_phash.png"
+	defer os.Remove(small)
+	if err := exec.Command("ffmpeg", "-y", "-noautorotate", "-i", src, "-vf", upright+"scale=32:32,format=gray", "-frames:v", "1", small).Run(); err != nil {
+		return nil, err
+	}
+	h, err := hashFile(small)
+	if err != nil { return nil, err }
+	return []uint64{h}, nil
+}
+
+// videoHashes hashes the first maxKeyframes keyframes of src; decoding only
+// keyframes keeps it cheap for long videos.
+func videoHashes(tmpBase, src string) ([]uint64, error) {
+	pattern := tmpBase + "_kf%02d.png"
+	if err := exec.Command("ffmpeg", "-y", "-skip_frame", "nokey", "-i", src,
+		"-vf", "scale=32:32,format=gray", "-vsync", "vfr", "-frames:v", strconv.Itoa(maxKeyframes), pattern).Run(); err != nil {
+		return nil, err
+	}
+	frames, _ := filepath.Glob(tmpBase + "_kf*.png")
+	sort.Strings(frames)
+	var out []uint64
+	for _, f := range frames {
+		h, err := hashFile(f)
+		os.Remove(f)
+		if err != nil { return nil, err }
+		out = append(out, h)
+	}
+	return out, nil
+}
+
+func hashFile(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil { return 0, err }
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil { return 0, err }
+	return moderation.PHash(img), nil
+}
+
+// checkDuplicates indexes the hashes of media item idx and flags the post
+// if they match someone else's earlier post.
+func checkDuplicates(postID string, idx int, hashes []uint64) error {
+	doc, err := fs.Collection("posts").Doc(postID).Get(ctx)
+	if err != nil { return err }
+	post, err := models.PostFromDoc(doc)
+	if err != nil { return err }
+
+	if err := moderation.IndexMedia(ctx, fs, post, idx, hashes); err != nil { return fmt.Errorf("index: %w", err) }
+	dup, err := moderation.FindOriginal(ctx, fs, post, idx, hashes, dupMaxDistance)
+	if err != nil { return fmt.Errorf("search: %w", err) }
+	if dup == nil { return nil }
+	log.Printf("post %s media %d: duplicate of post %s (distance %d)", postID, idx, dup.PostID, dup.Distance)
+	return moderation.FlagDuplicate(ctx, fs, postID, *dup, dupAutoCredit)
+}
+
+func envInt(k string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(k))
+	if err != nil { return def }
+	return n
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"image/jpeg"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
+)
+
+// Photo pipeline: every uploaded image is re-encoded into a few widths as
+// WebP and JPEG. Re-encoding with -map_metadata -1 drops EXIF (incl. GPS),
+// so clients only ever see the variants, never the original upload; the
+// orientation tag goes with it, so the pixels are turned upright first
+// (see orientation.go).
+
+var variantWidths = []int{320, 640, 1080}
+
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".heic": true}
+
+var variantFormats = []struct {
+	format, ext, contentType string
+	args                     []string
+}{
+	{"webp", "webp", "image/webp", []string{"-c:v", "libwebp", "-quality", "80"}},
+	{"jpeg", "jpg", "image/jpeg", []string{"-q:v", "3"}},
+}
+
+// processImage builds the variants of object and records them on the post.
+// With aspect > 0 (width/height) the image is first centre-cropped to it.
+func processImage(object, postID string, idx int, aspect float64) error {
+	ext := filepath.Ext(object)
+	base := strings.TrimSuffix(object, ext)
+	tmpBase := filepath.Join(os.TempDir(), strings.ReplaceAll(base, "/", "_"))
+
+	src := tmpBase + ext
+	defer os.Remove(src)
+	if err := download(object, src); err != nil { return fmt.Errorf("dl: %w", err) }
+
+	width, height, upright, err := probeImage(src)
+	if err != nil { return fmt.Errorf("ffprobe: %w", err) }
+	crop := ""
+	if aspect > 0 { width, height, crop = cropTo(width, height, aspect) }
+	filters := upright + crop
+
+	var variants []models.ImageVariant
+	for i, w := range variantWidths {
+		// never upscale; the smallest variant is always produced
+		if w > width && i > 0 { break }
+		if w > width { w = width }
+		h := variantHeight(width, height, w)
+
+		for _, f := range variantFormats {
+			local := fmt.Sprintf("%s_w%d.%s", tmpBase, w, f.ext)
+			args := append([]string{"-y", "-noautorotate", "-i", src, "-map_metadata", "-1",
+				"-vf", filters + fmt.Sprintf("scale=%d:%d", w, h), "-frames:v", "1"}, f.args...)
+			if err := exec.Command("ffmpeg", append(args, local)...).Run(); err != nil {
+				os.Remove(local)
+				return fmt.Errorf("ffmpeg %s w%d: %w", f.format, w, err)
+			}
+			obj := fmt.Sprintf("%s_w%d.%s", base, w, f.ext)
+			err := upload(obj, local, f.contentType)
+			os.Remove(local)
+			if err != nil { return fmt.Errorf("up %s: %w", obj, err) }
+			variants = append(variants, models.ImageVariant{Path: obj, Format: f.format, Width: w, Height: h})
+		}
+	}
+
+	hash, err := blurHashOf(tmpBase, src, filters)
+	if err != nil { return fmt.Errorf("blurhash: %w", err) }
+
+	err = markProcessed(postID, idx, func(m *models.MediaItem) {
+		m.Variants = variants
+		m.Width, m.Height = width, height
+		m.BlurHash = hash
+		m.ThumbnailPath = thumbnailVariant(variants)
+	})
+	if err != nil { return err }
+	if err := moderateMedia(postID, idx, moderation.Image{Path: src, Object: object, ContentType: imageContentType(ext)}); err != nil { return err }
+
+	hashes, err := imageHashes(tmpBase, src, upright)
+	if err != nil { return fmt.Errorf("phash: %w", err) }
+	return checkDuplicates(postID, idx, hashes)
+}
+
+// variantHeight keeps the aspect ratio at width w; never 0, which ffmpeg
+// refuses, even for extreme panoramas.
+func variantHeight(width, height, w int) int { return max(height*w/width, 1) }
+
+func imageContentType(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".png":
+		return "image/png"
+	case ".webp":
+		return "image/webp"
+	case ".heic":
+		return "image/heic"
+	}
+	return "image/jpeg"
+}
+
+// probeSize returns the stored pixel dimensions of the first video stream,
+// before any rotation; see probeImage.
+func probeSize(path string) (int, int, error) {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height", "-of", "csv=p=0:s=x", path).Output()
+	if err != nil { return 0, 0, err }
+	w, h, ok := strings.Cut(strings.TrimSpace(string(out)), "x")
+	if !ok { return 0, 0, fmt.Errorf("unexpected output %q", out) }
+	width, err := strconv.Atoi(w)
+	if err != nil { return 0, 0, err }
+	height, err := strconv.Atoi(h)
+	if err != nil { return 0, 0, err }
+	if width == 0 || height == 0 { return 0, 0, fmt.Errorf("zero dimension %dx%d", width, height) }
+	return width, height, nil
+}
+
+// blurHashOf hashes a 32px-wide JPEG rendition of src, after the upright
+// and crop filters if any; the hash only encodes low frequencies, so the
+// tiny input keeps it cheap without changing it.
+func blurHashOf(tmpBase, src, filters string) (string, error) {
+	small := tmpBase + "_blur.jpg"
+	defer os.Remove(small)
+	if err := exec.Command("ffmpeg", "-y", "-noautorotate", "-i", src, "-vf", filters+"scale=32:-1", "-frames:v", "1", small).Run(); err != nil {
+		return "", err
+	}
+	f, err := os.Open(small)
+	if err != nil { return "", err }
+	defer f.Close()
+	img, err := jpeg.Decode(f)
+	if err != nil { return "", err }
+	return blurHash(img, 4, 3), nil
+}
+
+// thumbnailVariant picks the JPEG closest to the 640px video thumbnails.
+func thumbnailVariant(variants []models.ImageVariant) string {
+	best := models.ImageVariant{}
+	for _, v := range variants {
+		if v.Format != "jpeg" { continue }
+		if best.Path == "" || abs(v.Width-640) < abs(best.Width-640) { best = v }
+	}
+	return best.Path
+}
+
+func abs(n int) int {
+	if n < 0 { return -n }
+	return n
+}
@@ -14,18 +14,25 @@ import (
 	"strings"
 
 	"cloud.google.com/go/firestore"
-	"cloud.google.com/go/storage"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/auth"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/blobstore"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/events"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+	"github.com/oguzkopan/cosmetics-social-backend/shared/moderation"
 )
 
 var (
 	projectID = os.Getenv("GOOGLE_CLOUD_PROJECT")
 	port      = "8080"
+	postTopic = os.Getenv("POST_EVENTS_TOPIC") // "post-events"
 )
 
 var (
 	ctx context.Context
 	fs  *firestore.Client
-	st  *storage.Client
+	st  blobstore.Store
+	clf moderation.Classifier
 )
 
 type pubSub struct {
@@ -35,6 +42,8 @@ type pubSub struct {
 	} `json:"message"`
 }
 
+// gcsEvent is the object-finalize notification. Objects are read from the
+// store blobstore.FromEnv opens, so Bucket is informational.
 type gcsEvent struct {
 	Bucket      string `json:"bucket"`
 	Name        string `json:"name"`
@@ -45,9 +54,14 @@ func main() {
 	ctx = context.Background()
 	var err error
 	if fs, err = firestore.NewClient(ctx, projectID); err != nil { log.Fatal(err) }
-	if st, err = storage.NewClient(ctx); err != nil { log.Fatal(err) }
-
-	http.HandleFunc("/pubsub", handle)
+	if st, err = blobstore.FromEnv(ctx); err != nil { log.Fatal(err) }
+	if clf, err = moderation.FromEnv(); err != nil { log.Fatal(err) }
+	if postTopic == "" { log.Fatal("POST_EVENTS_TOPIC must be set") }
+	if err = events.Init(ctx, projectID); err != nil { log.Fatal(err) }
+	pushAuth, err := auth.Invoker() // the push subscription's OIDC token
+	if err != nil { log.Fatalf("pubsub push: %v", err) }
+
+	http.Handle("/pubsub", pushAuth(http.HandlerFunc(handle)))
 	http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) { w.Write([]byte("video OK")) })
 	log.Fatal(http.ListenAndServe(":"+port, nil))
 }
@@ -62,35 +76,98 @@ func handle(w http.ResponseWriter, r *http.Request) {
 	b, _ := base64.StdEncoding.DecodeString(push.Message.Data)
 	_ = json.Unmarshal(b, &ev)
 
-	if !strings.HasSuffix(ev.Name, ".mp4") { w.WriteHeader(200); return }
+	// only original uploads – our own thumbnails/variants land here too
+	postID, idx, ok := models.ParseMediaObjectPath(ev.Name)
+	if !ok { w.WriteHeader(200); return }
+
+	switch ext := strings.ToLower(filepath.Ext(ev.Name)); {
+	case ext == ".mp4":
+		if err := processVideo(ev.Name, postID, idx); err != nil { log.Println("video:", err) }
+	case imageExts[ext]:
+		if err := processUploadedImage(ev.Name, postID, idx); err != nil { log.Println("image:", err) }
+	}
+	w.WriteHeader(200)
+}
 
-	tmp := filepath.Join(os.TempDir(), "video.mp4")
-	if err := download(ev.Bucket, ev.Name, tmp); err != nil { log.Println("dl:", err); return }
+func processVideo(object, postID string, idx int) error {
+	// per-object temp names: carousel items are processed concurrently
+	base := strings.ReplaceAll(strings.TrimSuffix(object, ".mp4"), "/", "_")
+	tmp := filepath.Join(os.TempDir(), base+".mp4")
+	defer os.Remove(tmp)
+	if err := download(object, tmp); err != nil { return fmt.Errorf("dl: %w", err) }
 
-	thumb := filepath.Join(os.TempDir(), "thumb.jpg")
+	thumb := filepath.Join(os.TempDir(), base+"_thumb.jpg")
+	defer os.Remove(thumb)
 	if err := exec.Command("ffmpeg", "-y", "-i", tmp,
 		"-ss", "00:00:01.0", "-vframes", "1",
 		"-vf", "scale=640:-1", thumb).Run(); err != nil {
-		log.Println("ffmpeg:", err); return
+		return fmt.Errorf("ffmpeg: %w", err)
 	}
 
-	thumbObj := strings.TrimSuffix(ev.Name, ".mp4") + "_thumb.jpg"
-	if err := upload(ev.Bucket, thumbObj, thumb); err != nil { log.Println("up:", err) }
+	thumbObj := strings.TrimSuffix(object, ".mp4") + "_thumb.jpg"
+	if err := upload(thumbObj, thumb, "image/jpeg"); err != nil { return fmt.Errorf("up: %w", err) }
+
+	// bucket is private – readers get signed URLs from the object path
+	if err := markProcessed(postID, idx, func(m *models.MediaItem) { m.ThumbnailPath = thumbObj }); err != nil { return err }
+	if err := moderateMedia(postID, idx, moderation.Image{Path: thumb, Object: thumbObj, ContentType: "image/jpeg"}); err != nil { return err }
 
-	postID := extractPostID(ev.Name)
-	if postID != "" {
-		url := fmt.Sprintf("https://storage.googleapis.com/%s/%s", ev.Bucket, thumbObj)
-		_, _ = fs.Collection("posts").Doc(postID).Set(ctx, map[string]any{
-			"thumbnailURL": url, "processed": true,
-		}, firestore.MergeAll)
+	hashes, err := videoHashes(filepath.Join(os.TempDir(), base), tmp)
+	if err != nil { return fmt.Errorf("phash: %w", err) }
+	return checkDuplicates(postID, idx, hashes)
+}
+
+// moderateMedia classifies a processed media item (videos by their
+// thumbnail frame) and records the verdict on the post. When that blocks
+// or unblocks a post that is already out, feeds are told so cached copies
+// drop or regain it.
+func moderateMedia(postID string, idx int, img moderation.Image) error {
+	res := moderation.Picture(ctx, clf, img)
+	before, after, err := moderation.ApplyToPost(ctx, fs, postID, fmt.Sprintf("media:%d", idx), res)
+	if err != nil { return fmt.Errorf("moderation: %w", err) }
+	if after.Decision != models.ModerationAllow { log.Printf("post %s media %d: %s %v", postID, idx, after.Decision, res.Labels) }
+	if before.Blocked() == after.Blocked() { return nil }
+
+	doc, err := fs.Collection("posts").Doc(postID).Get(ctx)
+	if err != nil { return err }
+	post, err := models.PostFromDoc(doc)
+	if err != nil { return err }
+	if post.IsPublished() && post.IsPublic() {
+		events.Publish(ctx, postTopic, "POST_VISIBILITY_CHANGED", map[string]string{
+			"postID": post.ID, "authorID": post.AuthorID, "visibility": post.Visibility,
+		})
 	}
-	w.WriteHeader(200)
+	return nil
+}
+
+// markProcessed applies update to media item idx, flags it done and the post
+// as processed once every item is. Carousel items finish concurrently, hence
+// the transaction.
+func markProcessed(postID string, idx int, update func(*models.MediaItem)) error {
+	ref := fs.Collection("posts").Doc(postID)
+	return fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		post, err := models.PostFromDoc(doc)
+		if err != nil { return err }
+
+		items := post.Items()
+		if idx >= len(items) { return fmt.Errorf("post %s has no media item %d", postID, idx) }
+		update(&items[idx])
+		items[idx].Processed = true
+
+		updates := []firestore.Update{
+			{Path: "media", Value: items},
+			{Path: "processed", Value: models.AllProcessed(items)},
+		}
+		if idx == 0 { updates = append(updates, firestore.Update{Path: "thumbnailPath", Value: items[0].ThumbnailPath}) }
+		return tx.Update(ref, updates)
+	})
 }
 
 // ——— helpers ————————————————————————————————
 
-func download(bucket, object, dest string) error {
-	rc, err := st.Bucket(bucket).Object(object).NewReader(ctx)
+func download(object, dest string) error {
+	rc, err := st.NewReader(ctx, object)
 	if err != nil { return err }
 	defer rc.Close()
 	file, _ := os.Create(dest)
@@ -99,18 +176,11 @@ func download(bucket, object, dest string) error {
 	return err
 }
 
-func upload(bucket, object, src string) error {
-	file, _ := os.Open(src); defer file.Close()
-	wc := st.Bucket(bucket).Object(object).NewWriter(ctx)
-	defer wc.Close()
-	wc.ContentType = "image/jpeg"
-	_, err := io.Copy(wc, file)
-	return err
-}
-
-func extractPostID(obj string) string {
-	parts := strings.Split(obj, "/")
-	if len(parts) < 3 { return "" }
-	filename := parts[len(parts)-1] // "<postID>.mp4"
-	return strings.TrimSuffix(filename, filepath.Ext(filename))
+func upload(object, src, contentType string) error {
+	file, err := os.Open(src)
+	if err != nil { return err }
+	defer file.Close()
+	wc := st.NewWriter(ctx, object, contentType)
+	if _, err := io.Copy(wc, file); err != nil { wc.Close(); return err }
+	return wc.Close()
 }
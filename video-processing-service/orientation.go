@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Phones store portrait photos as landscape pixels plus an orientation
+// tag. Variants drop all metadata, so the rotation has to be applied to the
+// pixels first. ffmpeg's own autorotation only covers some formats and
+// versions, so it is switched off (-noautorotate) and the orientation is
+// read here instead: from EXIF for JPEG and WebP, else from the stream's
+// display matrix (HEIC).
+
+// orientFilters turns the stored pixels upright for each EXIF orientation
+// (1–8); each ends in "," so it can prefix further filters.
+var orientFilters = map[int]string{
+	2: "hflip,",
+	3: "hflip,vflip,",
+	4: "vflip,",
+	5: "transpose=0,", // transpose: mirror across the top-left diagonal
+	6: "transpose=1,", // rotate 90° clockwise
+	7: "transpose=3,", // transverse
+	8: "transpose=2,", // rotate 90° counter-clockwise
+}
+
+// probeImage returns the upright size of the image at path and the filter
+// prefix that makes its pixels upright. Inputs must be read with
+// -noautorotate for the filter to be right.
+func probeImage(path string) (width, height int, upright string, err error) {
+	width, height, err = probeSize(path)
+	if err != nil { return 0, 0, "", err }
+	o := fileOrientation(path)
+	if o == 0 { o = rotationOrientation(path) }
+	if o >= 5 { width, height = height, width } // rotated a quarter turn
+	return width, height, orientFilters[o], nil
+}
+
+// fileOrientation reads the EXIF orientation of a JPEG or WebP file; 0 if
+// there is none.
+func fileOrientation(path string) int {
+	f, err := os.Open(path)
+	if err != nil { return 0 }
+	defer f.Close()
+	b, err := io.ReadAll(io.LimitReader(f, 1<<20)) // EXIF sits near the start
+	if err != nil { return 0 }
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xD8}):
+		return jpegOrientation(b)
+	case len(b) >= 12 && string(b[0:4]) == "RIFF" && string(b[8:12]) == "WEBP":
+		return webpOrientation(b)
+	}
+	return 0
+}
+
+// jpegOrientation walks the JPEG markers up to the image data looking for
+// an APP1 Exif segment.
+func jpegOrientation(b []byte) int {
+	for i := 2; i+4 <= len(b); {
+		if b[i] != 0xFF { return 0 }
+		marker := b[i+1]
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) { i += 2; continue }
+		if marker == 0xDA || marker == 0xD9 { return 0 } // start of scan / end of image
+		n := int(binary.BigEndian.Uint16(b[i+2:]))
+		if n < 2 || i+2+n > len(b) { return 0 }
+		seg := b[i+4 : i+2+n]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) { return tiffOrientation(seg[6:]) }
+		i += 2 + n
+	}
+	return 0
+}
+
+// webpOrientation looks for the EXIF chunk of an extended WebP file.
+func webpOrientation(b []byte) int {
+	for i := 12; i+8 <= len(b); {
+		n := int(binary.LittleEndian.Uint32(b[i+4:]))
+		if i+8+n > len(b) { return 0 }
+		if string(b[i:i+4]) == "EXIF" { return tiffOrientation(bytes.TrimPrefix(b[i+8:i+8+n], []byte("Exif\x00\x00"))) }
+		i += 8 + n + n%2 // chunks are padded to even sizes
+	}
+	return 0
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF header.
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 { return 0 }
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	if order.Uint16(t[2:]) != 42 { return 0 }
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > len(t) { return 0 }
+	entries := int(order.Uint16(t[ifd:]))
+	for e := 0; e < entries; e++ {
+		at := ifd + 2 + 12*e
+		if at+12 > len(t) { return 0 }
+		if order.Uint16(t[at:]) != 0x0112 { continue }
+		if o := int(order.Uint16(t[at+8:])); o >= 1 && o <= 8 { return o }
+		return 0
+	}
+	return 0
+}
+
+// rotationOrientation maps the rotation of the first video stream's display
+// matrix to an EXIF orientation; 0 when there is none.
+func rotationOrientation(path string) int {
+	out, err := exec.Command("ffprobe", "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream_side_data=rotation", "-of", "csv=p=0", path).Output()
+	if err != nil { return 0 }
+	rot, err := strconv.Atoi(strings.TrimSpace(strings.Split(strings.TrimSpace(string(out)), "\n")[0]))
+	if err != nil { return 0 }
+	switch (rot%360 + 360) % 360 {
+	case 90:
+		return 8
+	case 180:
+		return 3
+	case 270:
+		return 6
+	}
+	return 0
+}
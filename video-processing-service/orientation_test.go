@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// tiffWith builds a TIFF header whose IFD0 holds a dummy tag and, unless
+// orientation is 0, an Orientation tag.
+func tiffWith(order binary.AppendByteOrder, orientation int) []byte {
+	b := []byte("II\x2a\x00\x08\x00\x00\x00")
+	if order == binary.BigEndian { b = []byte("MM\x00\x2a\x00\x00\x00\x08") }
+	tags := [][2]uint16{{0x010F, 0}} // Make
+	if orientation != 0 { tags = append(tags, [2]uint16{0x0112, uint16(orientation)}) }
+	b = order.AppendUint16(b, uint16(len(tags)))
+	for _, t := range tags {
+		b = order.AppendUint16(b, t[0])
+		b = order.AppendUint16(b, 3) // SHORT
+		b = order.AppendUint32(b, 1)
+		b = order.AppendUint16(b, t[1])
+		b = append(b, 0, 0)
+	}
+	return order.AppendUint32(b, 0) // no next IFD
+}
+
+func jpegWith(tiff []byte) []byte {
+	b := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 'J', 'F'} // SOI, a short APP0
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	b = append(b, 0xFF, 0xE1)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(seg)+2))
+	b = append(b, seg...)
+	return append(b, 0xFF, 0xDA, 0x00, 0x02, 0xFF, 0xD9)
+}
+
+func webpWith(tiff []byte) []byte {
+	var body []byte
+	body = append(body, "VP8X"...)
+	body = binary.LittleEndian.AppendUint32(body, 10)
+	body = append(body, make([]byte, 10)...)
+	body = append(body, "EXIF"...)
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(tiff)))
+	body = append(body, tiff...)
+	if len(tiff)%2 == 1 { body = append(body, 0) }
+	b := append([]byte("RIFF"), binary.LittleEndian.AppendUint32(nil, uint32(len(body)+4))...)
+	return append(append(b, "WEBP"...), body...)
+}
+
+func TestTIFFOrientation(t *testing.T) {
+	tests := []struct {
+		name string
+		tiff []byte
+		want int
+	}{
+		{"little endian, rotate 90", tiffWith(binary.LittleEndian, 6), 6},
+		{"big endian, rotate 270", tiffWith(binary.BigEndian, 8), 8},
+		{"no orientation tag", tiffWith(binary.LittleEndian, 0), 0},
+		{"out of range value", tiffWith(binary.BigEndian, 9), 0},
+		{"truncated", tiffWith(binary.LittleEndian, 6)[:12], 0},
+		{"not TIFF", []byte("PK\x03\x04 not a tiff header"), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tiffOrientation(tt.tiff); got != tt.want { t.Errorf("tiffOrientation = %d, want %d", got, tt.want) }
+		})
+	}
+}
+
+func TestContainerOrientation(t *testing.T) {
+	tiff := tiffWith(binary.BigEndian, 6)
+	if got := jpegOrientation(jpegWith(tiff)); got != 6 { t.Errorf("jpegOrientation = %d, want 6", got) }
+	if got := jpegOrientation([]byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02}); got != 0 { t.Errorf("jpegOrientation without EXIF = %d, want 0", got) }
+	if got := webpOrientation(webpWith(tiff)); got != 6 { t.Errorf("webpOrientation = %d, want 6", got) }
+	if got := webpOrientation(webpWith(tiff[:len(tiff)-1])); got != 6 { t.Errorf("webpOrientation, padded chunk = %d, want 6", got) }
+}
+
+func TestVariantHeight(t *testing.T) {
+	tests := []struct{ width, height, w, want int }{
+		{4000, 3000, 1080, 810},
+		{3000, 4000, 320, 426},
+		{20000, 10, 320, 1}, // would be 0
+		{320, 1, 320, 1},
+	}
+	for _, tt := range tests {
+		if got := variantHeight(tt.width, tt.height, tt.w); got != tt.want {
+			t.Errorf("variantHeight(%d, %d, %d) = %d, want %d", tt.width, tt.height, tt.w, got, tt.want)
+		}
+	}
+}
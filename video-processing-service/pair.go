@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"cloud.google.com/go/firestore"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+// Before/after pipeline: the two photos of a before/after post are rendered
+// at one aspect ratio so clients can overlay them behind a slider. Each
+// upload only records its photo's size; the upload that finds both sizes
+// known picks the ratio and processes both photos. The transaction makes
+// sure exactly one of two concurrent uploads does.
+
+// processUploadedImage processes an uploaded photo, or hands it to the
+// pair pipeline when it belongs to a before/after post.
+func processUploadedImage(object, postID string, idx int) error {
+	doc, err := fs.Collection("posts").Doc(postID).Get(ctx)
+	if err != nil { return err }
+	post, err := models.PostFromDoc(doc)
+	if err != nil { return err }
+	if !post.IsBeforeAfter() { return processImage(object, postID, idx, 0) }
+	return processPairItem(object, postID, idx)
+}
+
+func processPairItem(object, postID string, idx int) error {
+	if idx != models.BeforeIndex && idx != models.AfterIndex { return fmt.Errorf("post %s: no pair item %d", postID, idx) }
+	ext := filepath.Ext(object)
+	src := filepath.Join(os.TempDir(), strings.ReplaceAll(strings.TrimSuffix(object, ext), "/", "_")+"_probe"+ext)
+	defer os.Remove(src)
+	if err := download(object, src); err != nil { return fmt.Errorf("dl: %w", err) }
+	width, height, _, err := probeImage(src) // upright size, so portrait photos crop as portrait
+	if err != nil { return fmt.Errorf("ffprobe: %w", err) }
+
+	items, aspect, err := recordPairSize(postID, idx, width, height)
+	if err != nil || aspect == 0 { return err } // still waiting for the other photo
+
+	for i := models.BeforeIndex; i <= models.AfterIndex; i++ {
+		if err := processImage(items[i].Path, postID, i, aspect); err != nil { return fmt.Errorf("pair item %d: %w", i, err) }
+	}
+	return nil
+}
+
+// recordPairSize stores the original size of photo idx. Once both sizes
+// are known it also stores and returns the shared aspect ratio, 0 before.
+func recordPairSize(postID string, idx, width, height int) ([]models.MediaItem, float64, error) {
+	ref := fs.Collection("posts").Doc(postID)
+	var items []models.MediaItem
+	var aspect float64
+	err := fs.RunTransaction(ctx, func(c context.Context, tx *firestore.Transaction) error {
+		items, aspect = nil, 0
+		doc, err := tx.Get(ref)
+		if err != nil { return err }
+		post, err := models.PostFromDoc(doc)
+		if err != nil { return err }
+
+		items = post.Items()
+		if len(items) <= models.AfterIndex { return fmt.Errorf("post %s has %d media items, not a pair", postID, len(items)) }
+		items[idx].Width, items[idx].Height = width, height
+		updates := []firestore.Update{{Path: "media", Value: items}}
+
+		before, after := items[models.BeforeIndex], items[models.AfterIndex]
+		if before.Width > 0 && after.Width > 0 {
+			aspect = pairAspect(before, after)
+			updates = append(updates, firestore.Update{Path: "beforeAfter.aspectRatio", Value: aspect})
+		}
+		return tx.Update(ref, updates)
+	})
+	return items, aspect, err
+}
+
+// pairAspect is the narrower of the two photos' aspect ratios, so the
+// wider photo loses its sides rather than either being letterboxed, and a
+// portrait pair stays portrait. Rounded so that both crops agree.
+func pairAspect(a, b models.MediaItem) float64 {
+	r := math.Min(float64(a.Width)/float64(a.Height), float64(b.Width)/float64(b.Height))
+	return math.Round(r*1000) / 1000
+}
+
+// cropTo returns the largest centred width×height box of aspect inside a
+// width×height image, and the ffmpeg filter prefix that cuts it out.
+func cropTo(width, height int, aspect float64) (int, int, string) {
+	w, h := width, height
+	if float64(width)/float64(height) > aspect {
+		w = int(math.Round(float64(height) * aspect))
+	} else {
+		h = int(math.Round(float64(width) / aspect))
+	}
+	w, h = max(w, 1), max(h, 1)
+	return w, h, fmt.Sprintf("crop=%d:%d,", w, h)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/oguzkopan/cosmetics-social-backend/shared/models"
+)
+
+func TestPairAspect(t *testing.T) {
+	item := func(w, h int) models.MediaItem { return models.MediaItem{Type: "image", Width: w, Height: h} }
+	tests := []struct {
+		name       string
+		before     models.MediaItem
+		after      models.MediaItem
+		wantAspect float64
+	}{
+		{"portrait pair", item(1080, 1350), item(1080, 1920), 0.563},
+		{"landscape pair", item(1920, 1080), item(1600, 1200), 1.333},
+		{"portrait and landscape", item(1080, 1350), item(1920, 1080), 0.8},
+		{"order does not matter", item(1920, 1080), item(1080, 1350), 0.8},
+		{"squares", item(1000, 1000), item(640, 640), 1},
+		{"rounded to 3 decimals", item(3000, 2000), item(2000, 3000), 0.667},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pairAspect(tt.before, tt.after); got != tt.wantAspect {
+				t.Errorf("pairAspect = %v, want %v", got, tt.wantAspect)
+			}
+		})
+	}
+}
+
+func TestCropTo(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		aspect        float64
+		wantW, wantH  int
+		wantFilter    string
+	}{
+		{"landscape to portrait cuts the sides", 1920, 1080, 0.8, 864, 1080, "crop=864:1080,"},
+		{"tall portrait cuts top and bottom", 1080, 1920, 0.8, 1080, 1350, "crop=1080:1350,"},
+		{"already at aspect", 1080, 1350, 0.8, 1080, 1350, "crop=1080:1350,"},
+		{"width rounds to nearest", 1001, 1000, 0.563, 563, 1000, "crop=563:1000,"},
+		{"height rounds to nearest", 1080, 1920, 0.563, 1080, 1918, "crop=1080:1918,"},
+		{"1px floor on height", 1, 1000, 1000, 1, 1, "crop=1:1,"},
+		{"1px floor on width", 1000, 1, 0.001, 1, 1, "crop=1:1,"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, filter := cropTo(tt.width, tt.height, tt.aspect)
+			if w != tt.wantW || h != tt.wantH || filter != tt.wantFilter {
+				t.Errorf("cropTo(%d, %d, %v) = %d, %d, %q, want %d, %d, %q",
+					tt.width, tt.height, tt.aspect, w, h, filter, tt.wantW, tt.wantH, tt.wantFilter)
+			}
+		})
+	}
+}